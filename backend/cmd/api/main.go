@@ -5,8 +5,16 @@ import (
 	"database/sql" // <--- TAMBAHAN PENTING
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"edora/backend/internal/analysis"
+	"edora/backend/internal/auth"
+	"edora/backend/internal/fracturerisk"
 	"edora/backend/internal/handler"
+	"edora/backend/internal/models"
+	"edora/backend/internal/reference"
 	"edora/backend/internal/repository"
 	"edora/backend/internal/service"
 	"edora/backend/pkg/database"
@@ -48,16 +56,33 @@ func main() {
 		log.Fatal("❌ Error: pgconn bukan tipe *sql.DB yang valid")
 	}
 
+	// Token signing keys. JWT_SIGNING_KEY / JWT_VERIFY_KEYS use "kid:alg:base64",
+	// old keys stay in JWT_VERIFY_KEYS until tokens signed with them expire.
+	tokens, err := newTokenManager()
+	if err != nil {
+		log.Fatalf("❌ FATAL: konfigurasi JWT tidak valid: %v", err)
+	}
+
 	// 3. Setup Fiber App
 	app := fiber.New(fiber.Config{
 		AppName: "Edora Health Backend",
+		// body tidak di-buffer server; batasnya ditegakkan LimitBody di bawah
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
 	app.Use(logger.New())
 	app.Use(recover.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, Idempotency-Key",
+	}))
+	// batas body dicek sebelum auth/signature supaya payload raksasa tidak dibaca utuh;
+	// hanya upload binary firmware yang boleh jauh di atas batas default
+	app.Use(handler.LimitBody(envInt("MAX_BODY_BYTES", fiber.DefaultBodyLimit), map[string]int{
+		"POST /api/v1/sync/reading":        envInt("SYNC_MAX_BODY_BYTES", 1<<20),
+		"POST /api/v1/sync/readings:batch": envInt("SYNC_BATCH_MAX_BODY_BYTES", 16<<20),
+		"POST /api/v1/firmware":            envInt("FIRMWARE_MAX_BODY_BYTES", 64<<20),
 	}))
 
 	// 4. Initialize Dependency Injection (Wiring)
@@ -70,44 +95,195 @@ func main() {
 	patientRepo := repository.NewPatientRepository(sqlDB)
 
 	// Service Layer
-	readingSvc := service.NewReadingService(readingRepo, deviceRepo)
+	// Kalibrasi phantom: default untuk fasilitas tanpa kebijakan sendiri
+	calibrationSvc := service.NewCalibrationService(repository.NewCalibrationRepository(sqlDB), deviceRepo,
+		models.CalibrationPolicy{
+			IntervalDays: envInt("CALIBRATION_INTERVAL_DAYS", 90),
+			Enforcement:  envOr("CALIBRATION_ENFORCEMENT", models.CalibrationEnforceFlag),
+		},
+		envFloat("CALIBRATION_TOLERANCE", 0.02))
+	signalLimits := service.DefaultSignalLimits()
+	signalLimits.MaxPoints = envInt("SIGNAL_MAX_POINTS", signalLimits.MaxPoints)
+	analysisModel, err := loadAnalysisModel()
+	if err != nil {
+		log.Fatalf("❌ FATAL: model analisis tidak valid: %v", err)
+	}
+	log.Printf("🦴 Model analisis BMD: %s", analysisModel.Version)
+	referenceData, err := loadReferenceData()
+	if err != nil {
+		log.Fatalf("❌ FATAL: data referensi populasi tidak valid: %v", err)
+	}
+	log.Printf("📊 Data referensi T/Z-score: %s", referenceData.Version)
+	diagnosisRules, err := loadDiagnosisRules()
+	if err != nil {
+		log.Fatalf("❌ FATAL: aturan diagnosis tidak valid: %v", err)
+	}
+	log.Printf("🩺 Aturan diagnosis: %s", diagnosisRules.Version)
+	riskModel, err := loadFractureRiskModel()
+	if err != nil {
+		log.Fatalf("❌ FATAL: model risiko fraktur tidak valid: %v", err)
+	}
+	log.Printf("🦴 Model risiko fraktur: %s", riskModel.Version)
+	readingSvc := service.NewReadingService(readingRepo, deviceRepo, patientRepo, calibrationSvc, service.ReadingConfig{
+		SignalLimits:  signalLimits,
+		Analysis:      analysisModel,
+		Reference:     referenceData,
+		ReferenceSite: envOr("REFERENCE_DEFAULT_SITE", reference.SiteFemoralNeck),
+		Diagnosis:     diagnosisRules,
+		FractureRisk:  riskModel,
+		DefaultLSC:    envFloat("TREND_DEFAULT_LSC", 0.03),
+	})
 	dashboardSvc := service.NewDashboardService(readingRepo, deviceRepo)
-	patientSvc := service.NewPatientService(patientRepo)
-	deviceSvc := service.NewDeviceService(deviceRepo)
+	patientSvc := service.NewPatientService(patientRepo, readingSvc)
+	deviceSvc := service.NewDeviceService(deviceRepo, calibrationSvc)
 
 	// Handler Layer
 	// User repository + auth handler
 	userRepo := repository.NewUserRepository(sqlDB)
-	auth := handler.NewAuthHandler(userRepo)
+	sessionRepo := repository.NewSessionRepository(sqlDB)
+	guardCfg := service.DefaultLoginGuardConfig()
+	guardCfg.MaxUserFailures = envInt("LOGIN_MAX_USER_FAILURES", guardCfg.MaxUserFailures)
+	guardCfg.MaxIPFailures = envInt("LOGIN_MAX_IP_FAILURES", guardCfg.MaxIPFailures)
+	guardCfg.Lockout = envDuration("LOGIN_LOCKOUT", guardCfg.Lockout)
+	loginGuard := service.NewLoginGuard(guardCfg)
+	pwPolicy := service.DefaultPasswordPolicy()
+	pwPolicy.MinLength = envInt("PASSWORD_MIN_LENGTH", pwPolicy.MinLength)
+	pwPolicy.RequireMixedCase = envBool("PASSWORD_REQUIRE_MIXED_CASE", pwPolicy.RequireMixedCase)
+	pwPolicy.RequireDigit = envBool("PASSWORD_REQUIRE_DIGIT", pwPolicy.RequireDigit)
+	pwPolicy.RequireSymbol = envBool("PASSWORD_REQUIRE_SYMBOL", pwPolicy.RequireSymbol)
+	authSvc := service.NewAuthService(userRepo, sessionRepo, tokens, loginGuard, service.AuthConfig{
+		RefreshTTL:       envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		MFARequiredRoles: strings.Split(envOr("MFA_REQUIRED_ROLES", "admin,doctor"), ","),
+		TOTPIssuer:       envOr("TOTP_ISSUER", "Edora"),
+		PasswordPolicy:   pwPolicy,
+		ResetTokenTTL:    envDuration("PASSWORD_RESET_TTL", 24*time.Hour),
+	})
+	authHandler := handler.NewAuthHandler(authSvc)
+	userHandler := handler.NewUserHandler(service.NewUserService(userRepo, sessionRepo, pwPolicy))
 	readingHandler := handler.NewReadingHandler(readingSvc)
 	dashHTTP := handler.NewDashboardHTTPHandler(dashboardSvc)
 	patientHandler := handler.NewPatientHandler(patientSvc)
-	deviceHandler := handler.NewDeviceHandler(deviceSvc)
+	// satu ambang offline untuk presence monitor dan default daftar device
+	offlineAfter := envDuration("DEVICE_OFFLINE_AFTER", 10*time.Minute)
+	deviceHandler := handler.NewDeviceHandler(deviceSvc, offlineAfter)
+	calibrationHandler := handler.NewCalibrationHandler(calibrationSvc)
+	firmwareSvc, err := service.NewFirmwareService(repository.NewFirmwareRepository(sqlDB), envOr("FIRMWARE_DIR", "data/firmware"))
+	if err != nil {
+		log.Fatalf("❌ FATAL: direktori firmware tidak bisa dipakai: %v", err)
+	}
+	firmwareHandler := handler.NewFirmwareHandler(firmwareSvc)
+	deviceAuth := handler.NewDeviceAuthHandler(service.NewDeviceAuthService(deviceRepo, envDuration("DEVICE_SIGNATURE_WINDOW", 5*time.Minute)))
 
 	// 5. Define Routes
 	api := app.Group("/api/v1")
 
-	// Auth
-	api.Post("/login", auth.Login)
+	// Auth (public)
+	api.Post("/login", authHandler.Login)
+	api.Post("/auth/refresh", authHandler.Refresh)
+	api.Post("/auth/logout", authHandler.Logout)
+	api.Post("/auth/mfa/verify", authHandler.VerifyMFA)
+	api.Post("/auth/password/reset", authHandler.ResetPassword)
+
+	// Self-service account routes, reachable before the account gates below are satisfied
+	selfService := authHandler.RequireToken()
+	api.Post("/auth/mfa/enroll", selfService, authHandler.EnrollTOTP)
+	api.Post("/auth/mfa/activate", selfService, authHandler.ActivateTOTP)
+	api.Post("/auth/mfa/recovery_codes", selfService, authHandler.RegenerateRecoveryCodes)
+	api.Post("/auth/password", selfService, authHandler.ChangePassword)
+
+	// IoT Sync: hanya scanner yang sudah diprovisioning (HMAC per device)
+	api.Post("/sync/reading", deviceAuth.RequireDevice(), readingHandler.SyncReading)
+	api.Post("/sync/readings\\:batch", deviceAuth.RequireDevice(), readingHandler.SyncReadingsBatch)
+	api.Post("/devices/heartbeat", deviceAuth.RequireDevice(), deviceHandler.Heartbeat)
+	api.Get("/devices/:serial/firmware", deviceAuth.RequireDevice(), firmwareHandler.Manifest)
+	api.Get("/devices/:serial/firmware/:version/binary", deviceAuth.RequireDevice(), firmwareHandler.Download)
 
-	// Dashboard & IoT Sync
-	api.Post("/sync/reading", readingHandler.SyncReading)
-	api.Get("/dashboard/stats", dashHTTP.Stats)
+	// Semua route di bawah ini wajib bearer token yang valid. Dipasang per route,
+	// bukan lewat group tanpa prefix, agar route yang tidak ada tetap 404.
+	authed := authHandler.RequireAuth()
+
+	// Role policy per route
+	var (
+		adminOnly = handler.RequireRole(models.RoleAdmin)
+		clinician = handler.RequireRole(models.RoleAdmin, models.RoleDoctor)
+		staff     = handler.RequireRole(models.RoleAdmin, models.RoleDoctor, models.RoleOperator)
+		ops       = handler.RequireRole(models.RoleAdmin, models.RoleOperator)
+	)
+
+	// Dashboard
+	api.Get("/dashboard/stats", authed, staff, dashHTTP.Stats)
 
 	// Patient Management (CRUD)
-	api.Get("/patients", patientHandler.List)
-	api.Post("/patients", patientHandler.Create)
-	api.Put("/patients/:id", patientHandler.Update)
-	api.Delete("/patients/:id", patientHandler.Delete)
+	api.Get("/patients", authed, staff, patientHandler.List)
+	api.Post("/patients", authed, staff, patientHandler.Create)
+	api.Put("/patients/:id", authed, staff, patientHandler.Update)
+	api.Delete("/patients/:id", authed, adminOnly, patientHandler.Delete)
+	// Kuesioner faktor risiko fraktur
+	api.Get("/patients/:id/risk_factors", authed, clinician, patientHandler.GetRiskFactors)
+	api.Put("/patients/:id/risk_factors", authed, clinician, patientHandler.SetRiskFactors)
+
+	// Readings yang hasil analisis klien & server berbeda
+	api.Get("/readings/discrepancies", authed, clinician, readingHandler.Discrepancies)
 
 	// Medical Records (scan)
-	api.Post("/medical_records", readingHandler.CreateMedicalRecord)
-	api.Get("/patients/:id/medical_records", readingHandler.GetPatientRecords)
+	api.Post("/medical_records", authed, clinician, readingHandler.CreateMedicalRecord)
+	// Review & tanda tangan dokter
+	api.Get("/medical_records/worklist", authed, clinician, readingHandler.Worklist)
+	api.Get("/medical_records/worklist/unassigned", authed, clinician, readingHandler.UnassignedQueue)
+	api.Post("/medical_records/:id/review", authed, clinician, readingHandler.Review)
+	api.Post("/medical_records/:id/sign", authed, clinician, readingHandler.Sign)
+	api.Post("/medical_records/:id/reject", authed, clinician, readingHandler.Reject)
+	api.Get("/patients/:id/medical_records", authed, clinician, readingHandler.GetPatientRecords)
+	api.Get("/patients/:id/trend", authed, clinician, readingHandler.PatientTrend)
 	// Allow creating medical record via patient-scoped route as well
-	api.Post("/patients/:id/medical_records", readingHandler.CreateMedicalRecord)
+	api.Post("/patients/:id/medical_records", authed, clinician, readingHandler.CreateMedicalRecord)
 
 	// Device Management
-	api.Get("/devices", deviceHandler.List)
+	api.Get("/devices", authed, staff, deviceHandler.List)
+	api.Post("/devices", authed, ops, deviceHandler.Register)
+	api.Get("/devices/:id", authed, staff, deviceHandler.Get)
+	api.Get("/devices/:id/uptime", authed, staff, deviceHandler.Uptime)
+	api.Get("/devices/:id/telemetry", authed, staff, deviceHandler.Telemetry)
+	api.Put("/devices/:id", authed, ops, deviceHandler.Update)
+	api.Post("/devices/:id/maintenance", authed, ops, deviceHandler.StartMaintenance)
+	api.Delete("/devices/:id/maintenance", authed, ops, deviceHandler.EndMaintenance)
+	api.Post("/devices/:id/decommission", authed, adminOnly, deviceHandler.Decommission)
+	api.Post("/devices/:serial/credentials", authed, adminOnly, deviceAuth.RotateCredentials)
+
+	// Kalibrasi device
+	api.Get("/devices/:id/calibrations", authed, staff, calibrationHandler.List)
+	api.Post("/devices/:id/calibrations", authed, ops, calibrationHandler.Record)
+	api.Get("/facilities/:facility/calibration_policy", authed, staff, calibrationHandler.GetPolicy)
+	api.Put("/facilities/:facility/calibration_policy", authed, adminOnly, calibrationHandler.SetPolicy)
+
+	// Firmware / OTA
+	api.Get("/firmware", authed, ops, firmwareHandler.List)
+	api.Post("/firmware", authed, adminOnly, firmwareHandler.Publish)
+	api.Put("/firmware/:version/rollout", authed, adminOnly, firmwareHandler.SetRollout)
+	api.Get("/firmware/:version/devices", authed, ops, firmwareHandler.Progress)
+	api.Get("/devices/:id/firmware_updates", authed, staff, firmwareHandler.DeviceUpdates)
+
+	// User Management (admin)
+	api.Get("/users", authed, adminOnly, userHandler.List)
+	api.Post("/users", authed, adminOnly, userHandler.Create)
+	api.Get("/users/:id", authed, adminOnly, userHandler.Get)
+	api.Put("/users/:id/role", authed, adminOnly, userHandler.UpdateRole)
+	api.Post("/users/:id/disable", authed, adminOnly, userHandler.Disable)
+	api.Post("/users/:id/enable", authed, adminOnly, userHandler.Enable)
+	api.Delete("/users/:id", authed, adminOnly, userHandler.Delete)
+	api.Post("/users/:id/unlock", authed, adminOnly, authHandler.Unlock)
+	api.Delete("/users/:id/mfa", authed, adminOnly, authHandler.ResetTOTP)
+	api.Post("/users/:id/password_reset", authed, adminOnly, authHandler.IssuePasswordReset)
+
+	// Session revocation (admin)
+	api.Get("/users/:id/sessions", authed, adminOnly, authHandler.ListSessions)
+	api.Delete("/users/:id/sessions", authed, adminOnly, authHandler.RevokeUserSessions)
+	api.Delete("/sessions/:id", authed, adminOnly, authHandler.RevokeSession)
+
+	// Background worker: device yang lama tidak terlihat -> offline
+	monitor := service.NewPresenceMonitor(deviceRepo,
+		envDuration("PRESENCE_CHECK_INTERVAL", time.Minute), offlineAfter)
+	go monitor.Run(ctx)
 
 	// 6. Start Server
 	log.Printf("🚀 Server Edora berjalan di port %s", port)
@@ -115,3 +291,126 @@ func main() {
 		log.Fatalf("failed to start server: %v", err)
 	}
 }
+
+func newTokenManager() (*auth.TokenManager, error) {
+	cfg := auth.Config{
+		Issuer:    envOr("JWT_ISSUER", "edora"),
+		AccessTTL: envDuration("JWT_ACCESS_TTL", 15*time.Minute),
+		Leeway:    envDuration("JWT_LEEWAY", 30*time.Second),
+	}
+
+	if spec := os.Getenv("JWT_SIGNING_KEY"); spec != "" {
+		k, err := auth.ParseKey(spec, true)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SigningKey = k
+	} else {
+		log.Println("⚠️  JWT_SIGNING_KEY kosong, memakai key sementara (token hilang saat restart)")
+		cfg.SigningKey = auth.EphemeralKey()
+	}
+
+	keys, err := auth.ParseKeys(os.Getenv("JWT_VERIFY_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.VerifyKeys = keys
+
+	return auth.NewTokenManager(cfg)
+}
+
+// loadAnalysisModel reads ANALYSIS_MODEL_FILE, or the model built into the
+// binary when it is not set.
+func loadAnalysisModel() (*analysis.Model, error) {
+	if path := os.Getenv("ANALYSIS_MODEL_FILE"); path != "" {
+		return analysis.LoadModel(path)
+	}
+	return analysis.DefaultModel()
+}
+
+// loadReferenceData reads the reference-population CSVs from
+// REFERENCE_DATA_DIR, or the dataset built into the binary when it is not
+// set.
+func loadReferenceData() (*reference.Dataset, error) {
+	if dir := os.Getenv("REFERENCE_DATA_DIR"); dir != "" {
+		return reference.Load(dir)
+	}
+	return reference.Default()
+}
+
+// loadDiagnosisRules reads DIAGNOSIS_RULES_FILE, or the rule set built into
+// the binary when it is not set.
+func loadDiagnosisRules() (*service.DiagnosisRules, error) {
+	if path := os.Getenv("DIAGNOSIS_RULES_FILE"); path != "" {
+		return service.LoadDiagnosisRules(path)
+	}
+	return service.DefaultDiagnosisRules()
+}
+
+// loadFractureRiskModel reads FRACTURE_RISK_MODEL_FILE, or the model built
+// into the binary when it is not set.
+func loadFractureRiskModel() (*fracturerisk.Model, error) {
+	if path := os.Getenv("FRACTURE_RISK_MODEL_FILE"); path != "" {
+		return fracturerisk.LoadModel(path)
+	}
+	return fracturerisk.DefaultModel()
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("⚠️  %s=%q bukan angka valid, pakai default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("⚠️  %s=%q bukan boolean valid, pakai default %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("⚠️  %s=%q bukan angka valid, pakai default %g", key, v, def)
+		return def
+	}
+	return f
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("⚠️  %s=%q bukan durasi valid, pakai default %s", key, v, def)
+		return def
+	}
+	return d
+}
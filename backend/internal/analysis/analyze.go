@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"edora/backend/internal/models"
+)
+
+// ErrNoSignal is returned when a reading carries no spectrum to analyse.
+var ErrNoSignal = errors.New("no signal to analyse")
+
+// Result is the server-side analysis of one spectrum.
+type Result struct {
+	AlgorithmVersion string             `json:"algorithm_version"`
+	BMD              float64            `json:"bmd"`
+	TScore           float64            `json:"t_score"`
+	Features         map[string]float64 `json:"features"`
+}
+
+// Analyze derives BMD and T-score from a validated signal (frequencies
+// strictly increasing). The signal must cover the model's low and high
+// frequency; phase is only needed when the model uses it.
+func (m *Model) Analyze(sig models.Signal) (*Result, error) {
+	if len(sig) == 0 {
+		return nil, ErrNoSignal
+	}
+
+	features := map[string]float64{}
+	zRef, err := interpolate(sig, m.ReferenceFrequency, impedance)
+	if err != nil {
+		return nil, err
+	}
+	features[FeatureImpedanceRef] = zRef
+
+	zLow, err := interpolate(sig, m.LowFrequency, impedance)
+	if err != nil {
+		return nil, err
+	}
+	zHigh, err := interpolate(sig, m.HighFrequency, impedance)
+	if err != nil {
+		return nil, err
+	}
+	if zHigh == 0 {
+		return nil, errors.New("impedance is zero at the high frequency")
+	}
+	features[FeatureDispersion] = zLow / zHigh
+
+	if _, used := m.Coefficients[FeaturePhaseRef]; used {
+		phi, err := interpolate(sig, m.ReferenceFrequency, phase)
+		if err != nil {
+			return nil, err
+		}
+		features[FeaturePhaseRef] = phi
+	}
+
+	bmd := m.Intercept
+	for name, coef := range m.Coefficients {
+		bmd += coef * features[name]
+	}
+	bmd = math.Max(bmd, 0)
+
+	return &Result{
+		AlgorithmVersion: m.Version,
+		BMD:              round(bmd, 3),
+		TScore:           round((bmd-m.YoungAdultMean)/m.YoungAdultSD, 1),
+		Features:         features,
+	}, nil
+}
+
+// Discrepancy compares client-reported values with the server's.
+type Discrepancy struct {
+	BMD     *float64 `json:"bmd,omitempty"`     // server minus client
+	TScore  *float64 `json:"t_score,omitempty"` // server minus client
+	Flagged bool     `json:"flagged"`
+}
+
+// Compare reports how far the client values (nil when not sent) are from
+// the result and flags differences beyond the model tolerances.
+func (m *Model) Compare(r *Result, clientBMD, clientTScore *float64) Discrepancy {
+	var d Discrepancy
+	if clientBMD != nil {
+		diff := round(r.BMD-*clientBMD, 3)
+		d.BMD = &diff
+		d.Flagged = d.Flagged || math.Abs(diff) > m.BMDTolerance
+	}
+	if clientTScore != nil {
+		diff := round(r.TScore-*clientTScore, 1)
+		d.TScore = &diff
+		d.Flagged = d.Flagged || math.Abs(diff) > m.TScoreTolerance
+	}
+	return d
+}
+
+func impedance(p models.SignalPoint) (float64, bool) { return p.Impedance, true }
+
+func phase(p models.SignalPoint) (float64, bool) {
+	if p.Phase == nil {
+		return 0, false
+	}
+	return *p.Phase, true
+}
+
+// interpolate returns the value at freq, linear in log-frequency between the
+// two surrounding points.
+func interpolate(sig models.Signal, freq float64, value func(models.SignalPoint) (float64, bool)) (float64, error) {
+	if freq < sig[0].Freq || freq > sig[len(sig)-1].Freq {
+		return 0, fmt.Errorf("signal does not cover %g Hz", freq)
+	}
+	for i := range sig {
+		if sig[i].Freq < freq {
+			continue
+		}
+		hi, ok := value(sig[i])
+		if !ok {
+			return 0, fmt.Errorf("point %d has no phase", i)
+		}
+		if sig[i].Freq == freq {
+			return hi, nil
+		}
+		lo, ok := value(sig[i-1])
+		if !ok {
+			return 0, fmt.Errorf("point %d has no phase", i-1)
+		}
+		t := (math.Log(freq) - math.Log(sig[i-1].Freq)) / (math.Log(sig[i].Freq) - math.Log(sig[i-1].Freq))
+		return lo + t*(hi-lo), nil
+	}
+	return 0, fmt.Errorf("signal does not cover %g Hz", freq)
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
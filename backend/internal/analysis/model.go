@@ -0,0 +1,92 @@
+// Package analysis derives bone mineral density from the raw impedance
+// spectrum a scanner records, so the server does not have to trust the
+// values computed on the tablet.
+package analysis
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+//go:embed default_model.json
+var defaultModel []byte
+
+// Features the model has coefficients for.
+const (
+	FeatureImpedanceRef = "impedance_ref" // |Z| at the reference frequency, ohm
+	FeaturePhaseRef     = "phase_ref"     // phase at the reference frequency, degrees
+	FeatureDispersion   = "dispersion"    // |Z| at the low over |Z| at the high frequency
+)
+
+// Model is a versioned calibration: BMD = intercept + sum(coefficient *
+// feature). Every reading analysed with it records Version.
+type Model struct {
+	Version     string `json:"version"`
+	Description string `json:"description"`
+
+	ReferenceFrequency float64 `json:"reference_frequency_hz"`
+	LowFrequency       float64 `json:"low_frequency_hz"`
+	HighFrequency      float64 `json:"high_frequency_hz"`
+
+	Intercept    float64            `json:"intercept"`
+	Coefficients map[string]float64 `json:"coefficients"`
+
+	// Young-adult reference for the T-score, g/cm².
+	YoungAdultMean float64 `json:"young_adult_mean"`
+	YoungAdultSD   float64 `json:"young_adult_sd"`
+
+	// A client value further than this from the server's is a discrepancy.
+	BMDTolerance    float64 `json:"bmd_tolerance"`
+	TScoreTolerance float64 `json:"t_score_tolerance"`
+}
+
+// DefaultModel returns the model built into the binary.
+func DefaultModel() (*Model, error) {
+	return ParseModel(defaultModel)
+}
+
+// LoadModel reads a model from a JSON file.
+func LoadModel(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseModel(data)
+}
+
+func ParseModel(data []byte) (*Model, error) {
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("analysis model: %w", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("analysis model %q: %w", m.Version, err)
+	}
+	return &m, nil
+}
+
+func (m *Model) validate() error {
+	switch {
+	case m.Version == "":
+		return errors.New("version required")
+	case m.LowFrequency <= 0 || m.LowFrequency >= m.HighFrequency:
+		return errors.New("low_frequency_hz must be positive and below high_frequency_hz")
+	case m.ReferenceFrequency < m.LowFrequency || m.ReferenceFrequency > m.HighFrequency:
+		return errors.New("reference_frequency_hz must lie between the low and high frequency")
+	case m.YoungAdultSD <= 0:
+		return errors.New("young_adult_sd must be positive")
+	case m.BMDTolerance <= 0 || m.TScoreTolerance <= 0:
+		return errors.New("tolerances must be positive")
+	}
+	for name := range m.Coefficients {
+		switch name {
+		case FeatureImpedanceRef, FeaturePhaseRef, FeatureDispersion:
+		default:
+			return fmt.Errorf("unknown feature %q", name)
+		}
+	}
+	return nil
+}
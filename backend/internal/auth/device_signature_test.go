@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeviceSignature(t *testing.T) {
+	const secret = "device-secret"
+	body := []byte(`{"client_reading_id":"r1"}`)
+
+	// independent computation of the documented signing string
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("1700000000\nn1\nPOST\n/api/v1/sync/reading\n" + string(body)))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := DeviceSignature(secret, "1700000000", "n1", "POST", "/api/v1/sync/reading", body); got != want {
+		t.Fatalf("DeviceSignature = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name                            string
+		secret, ts, nonce, method, path string
+		body                            []byte
+		wantOK                          bool
+	}{
+		{name: "unchanged", secret: secret, ts: "1700000000", nonce: "n1", method: "POST", path: "/api/v1/sync/reading", body: body, wantOK: true},
+		{name: "other secret", secret: "other", ts: "1700000000", nonce: "n1", method: "POST", path: "/api/v1/sync/reading", body: body},
+		{name: "other timestamp", secret: secret, ts: "1700000001", nonce: "n1", method: "POST", path: "/api/v1/sync/reading", body: body},
+		{name: "other nonce", secret: secret, ts: "1700000000", nonce: "n2", method: "POST", path: "/api/v1/sync/reading", body: body},
+		{name: "other method", secret: secret, ts: "1700000000", nonce: "n1", method: "PUT", path: "/api/v1/sync/reading", body: body},
+		{name: "other path", secret: secret, ts: "1700000000", nonce: "n1", method: "POST", path: "/api/v1/sync/readings:batch", body: body},
+		{name: "other body", secret: secret, ts: "1700000000", nonce: "n1", method: "POST", path: "/api/v1/sync/reading", body: []byte(`{"client_reading_id":"r2"}`)},
+		// the separators keep fields from sliding into each other
+		{name: "shifted fields", secret: secret, ts: "1700000000\nn1", nonce: "", method: "POST", path: "/api/v1/sync/reading", body: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyDeviceSignature(tt.secret, want, tt.ts, tt.nonce, tt.method, tt.path, tt.body); got != tt.wantOK {
+				t.Errorf("VerifyDeviceSignature = %v, want %v", got, tt.wantOK)
+			}
+		})
+	}
+}
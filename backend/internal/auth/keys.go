@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// ParseKey parses a key spec of the form "kid:alg:base64material".
+//
+// For HS256 the material is the shared secret. For EdDSA a signing key
+// expects the 32-byte seed, while a verify-only key (signing == false)
+// expects the 32-byte public key.
+func ParseKey(spec string, signing bool) (Key, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return Key{}, fmt.Errorf("auth: malformed key spec, want kid:alg:base64")
+	}
+	kid, alg := parts[0], parts[1]
+	material, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Key{}, fmt.Errorf("auth: key %q: %w", kid, err)
+	}
+
+	switch alg {
+	case AlgHS256:
+		if len(material) < 32 {
+			return Key{}, fmt.Errorf("auth: key %q: HS256 secret must be at least 32 bytes", kid)
+		}
+		return Key{ID: kid, Algorithm: AlgHS256, Secret: material}, nil
+	case AlgEdDSA:
+		if signing {
+			if len(material) != ed25519.SeedSize {
+				return Key{}, fmt.Errorf("auth: key %q: EdDSA seed must be %d bytes", kid, ed25519.SeedSize)
+			}
+			priv := ed25519.NewKeyFromSeed(material)
+			return Key{ID: kid, Algorithm: AlgEdDSA, PrivateKey: priv, PublicKey: priv.Public().(ed25519.PublicKey)}, nil
+		}
+		if len(material) != ed25519.PublicKeySize {
+			return Key{}, fmt.Errorf("auth: key %q: EdDSA public key must be %d bytes", kid, ed25519.PublicKeySize)
+		}
+		return Key{ID: kid, Algorithm: AlgEdDSA, PublicKey: ed25519.PublicKey(material)}, nil
+	}
+	return Key{}, fmt.Errorf("auth: key %q: unsupported alg %q", kid, alg)
+}
+
+// ParseKeys parses a comma separated list of verify-only key specs.
+func ParseKeys(specs string) ([]Key, error) {
+	var keys []Key
+	for _, spec := range strings.Split(specs, ",") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		k, err := ParseKey(spec, false)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// EphemeralKey returns a random HS256 key. Tokens signed with it do not
+// survive a restart, so it is only meant for local development.
+func EphemeralKey() Key {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return Key{ID: "dev-" + randomID()[:8], Algorithm: AlgHS256, Secret: b}
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Supported signing algorithms (JOSE "alg" header values).
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// Claims is the payload carried by an Edora access token.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti,omitempty"`
+	// SessionID links the token to its server-side session for revocation.
+	SessionID string `json:"sid,omitempty"`
+	// MFA is true when the user has TOTP enabled.
+	MFA bool `json:"mfa,omitempty"`
+	// PasswordChange is true while the user must change their password.
+	PasswordChange bool `json:"pwd_change,omitempty"`
+	// Purpose marks special-use tokens (e.g. PurposeMFA); access tokens leave it empty.
+	Purpose string `json:"pur,omitempty"`
+}
+
+// PurposeMFA marks the short-lived token handed out between the password
+// step and the TOTP step of a login.
+const PurposeMFA = "mfa"
+
+// Key is a single signing/verification key identified by its kid.
+// HS256 keys use Secret; EdDSA keys use PrivateKey (signing) and PublicKey.
+type Key struct {
+	ID         string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+func (k Key) canSign() bool {
+	switch k.Algorithm {
+	case AlgHS256:
+		return len(k.Secret) > 0
+	case AlgEdDSA:
+		return len(k.PrivateKey) == ed25519.PrivateKeySize
+	}
+	return false
+}
+
+// Config configures a TokenManager.
+type Config struct {
+	// SigningKey is used for every newly issued token.
+	SigningKey Key
+	// VerifyKeys are retired keys that are still accepted during rotation.
+	VerifyKeys []Key
+	Issuer     string
+	AccessTTL  time.Duration
+	// Leeway is the clock-skew tolerance applied to exp/nbf/iat checks.
+	Leeway time.Duration
+}
+
+// TokenManager issues and verifies signed JWT access tokens.
+type TokenManager struct {
+	signing Key
+	keys    map[string]Key
+	issuer  string
+	ttl     time.Duration
+	leeway  time.Duration
+	now     func() time.Time
+}
+
+func NewTokenManager(cfg Config) (*TokenManager, error) {
+	if cfg.SigningKey.ID == "" {
+		return nil, errors.New("auth: signing key requires a kid")
+	}
+	if !cfg.SigningKey.canSign() {
+		return nil, fmt.Errorf("auth: signing key %q cannot sign", cfg.SigningKey.ID)
+	}
+	if cfg.AccessTTL <= 0 {
+		cfg.AccessTTL = 15 * time.Minute
+	}
+	if cfg.Leeway < 0 {
+		cfg.Leeway = 0
+	}
+
+	keys := map[string]Key{cfg.SigningKey.ID: cfg.SigningKey}
+	for _, k := range cfg.VerifyKeys {
+		if k.ID == "" {
+			return nil, errors.New("auth: verify key requires a kid")
+		}
+		if _, dup := keys[k.ID]; dup {
+			return nil, fmt.Errorf("auth: duplicate kid %q", k.ID)
+		}
+		keys[k.ID] = k
+	}
+
+	return &TokenManager{
+		signing: cfg.SigningKey,
+		keys:    keys,
+		issuer:  cfg.Issuer,
+		ttl:     cfg.AccessTTL,
+		leeway:  cfg.Leeway,
+		now:     time.Now,
+	}, nil
+}
+
+// AccessTTL returns the lifetime of issued access tokens.
+func (m *TokenManager) AccessTTL() time.Duration { return m.ttl }
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Issue signs claims with the active key. IssuedAt, ExpiresAt, Issuer and ID
+// are filled in when left empty. It returns the token and its expiry.
+func (m *TokenManager) Issue(c Claims) (string, time.Time, error) {
+	now := m.now()
+	if c.IssuedAt == 0 {
+		c.IssuedAt = now.Unix()
+	}
+	if c.ExpiresAt == 0 {
+		c.ExpiresAt = now.Add(m.ttl).Unix()
+	}
+	if c.Issuer == "" {
+		c.Issuer = m.issuer
+	}
+	if c.ID == "" {
+		c.ID = randomID()
+	}
+
+	h, err := json.Marshal(header{Alg: m.signing.Algorithm, Typ: "JWT", Kid: m.signing.ID})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	signingInput := b64(h) + "." + b64(p)
+	sig, err := sign(m.signing, []byte(signingInput))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signingInput + "." + b64(sig), time.Unix(c.ExpiresAt, 0), nil
+}
+
+// Verify checks the signature, algorithm, issuer and time claims of token.
+func (m *TokenManager) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(rawHeader, &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, ok := m.keys[h.Kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// the key decides the algorithm, never the token
+	if h.Alg != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !verify(key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	rawPayload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(rawPayload, &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := m.now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(m.leeway)) {
+		return nil, ErrExpiredToken
+	}
+	if c.NotBefore != 0 && now.Add(m.leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return nil, ErrInvalidToken
+	}
+	if c.IssuedAt != 0 && now.Add(m.leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return nil, ErrInvalidToken
+	}
+	if m.issuer != "" && c.Issuer != m.issuer {
+		return nil, ErrInvalidToken
+	}
+	return &c, nil
+}
+
+func sign(k Key, input []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case AlgEdDSA:
+		return ed25519.Sign(k.PrivateKey, input), nil
+	}
+	return nil, fmt.Errorf("auth: unsupported alg %q", k.Algorithm)
+}
+
+func verify(k Key, input, sig []byte) bool {
+	switch k.Algorithm {
+	case AlgHS256:
+		if len(k.Secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(input)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case AlgEdDSA:
+		if len(k.PublicKey) != ed25519.PublicKeySize {
+			return false
+		}
+		return ed25519.Verify(k.PublicKey, input, sig)
+	}
+	return false
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hsKey(id string) Key {
+	return Key{ID: id, Algorithm: AlgHS256, Secret: []byte(strings.Repeat(id, 32))}
+}
+
+func edKey(id string) Key {
+	priv := ed25519.NewKeyFromSeed([]byte(strings.Repeat("s", ed25519.SeedSize)))
+	return Key{ID: id, Algorithm: AlgEdDSA, PrivateKey: priv, PublicKey: priv.Public().(ed25519.PublicKey)}
+}
+
+func newManager(t *testing.T, cfg Config, now time.Time) *TokenManager {
+	t.Helper()
+	m, err := NewTokenManager(cfg)
+	if err != nil {
+		t.Fatalf("NewTokenManager: %v", err)
+	}
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func TestTokenVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cfg := Config{SigningKey: hsKey("a"), Issuer: "edora", AccessTTL: 15 * time.Minute, Leeway: 30 * time.Second}
+
+	tests := []struct {
+		name   string
+		claims Claims
+		// at is when the token is verified, relative to now
+		at      time.Duration
+		tamper  func(string) string
+		wantErr error
+	}{
+		{name: "valid", claims: Claims{Subject: "u1", Role: "doctor"}},
+		{name: "within leeway after expiry", claims: Claims{Subject: "u1"}, at: 15*time.Minute + 20*time.Second},
+		{name: "expired", claims: Claims{Subject: "u1"}, at: 16 * time.Minute, wantErr: ErrExpiredToken},
+		{name: "not yet valid", claims: Claims{Subject: "u1", NotBefore: now.Add(time.Hour).Unix()}, wantErr: ErrInvalidToken},
+		{name: "issued in the future", claims: Claims{Subject: "u1", IssuedAt: now.Add(time.Hour).Unix(), ExpiresAt: now.Add(2 * time.Hour).Unix()}, wantErr: ErrInvalidToken},
+		{name: "other issuer", claims: Claims{Subject: "u1", Issuer: "someone-else"}, wantErr: ErrInvalidToken},
+		{
+			name:    "tampered payload",
+			claims:  Claims{Subject: "u1", Role: "doctor"},
+			tamper:  func(tok string) string { return replacePart(tok, 1, `{"sub":"u1","role":"admin","exp":9999999999}`) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "alg none",
+			claims:  Claims{Subject: "u1"},
+			tamper:  func(tok string) string { return replacePart(tok, 0, `{"alg":"none","typ":"JWT","kid":"a"}`) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "unknown kid",
+			claims:  Claims{Subject: "u1"},
+			tamper:  func(tok string) string { return replacePart(tok, 0, `{"alg":"HS256","typ":"JWT","kid":"zzz"}`) },
+			wantErr: ErrUnknownKey,
+		},
+		{name: "malformed", claims: Claims{Subject: "u1"}, tamper: func(string) string { return "a.b" }, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newManager(t, cfg, now)
+			tok, _, err := m.Issue(tt.claims)
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			if tt.tamper != nil {
+				tok = tt.tamper(tok)
+			}
+			m.now = func() time.Time { return now.Add(tt.at) }
+
+			c, err := m.Verify(tok)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (c.Subject != tt.claims.Subject || c.Role != tt.claims.Role || c.Issuer != "edora") {
+				t.Errorf("claims = %+v", c)
+			}
+		})
+	}
+}
+
+func replacePart(tok string, i int, raw string) string {
+	parts := strings.Split(tok, ".")
+	parts[i] = base64.RawURLEncoding.EncodeToString([]byte(raw))
+	return strings.Join(parts, ".")
+}
+
+func TestTokenKidRotation(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	old, cur, ed := hsKey("old"), hsKey("new"), edKey("ed")
+	edPublic := Key{ID: ed.ID, Algorithm: AlgEdDSA, PublicKey: ed.PublicKey}
+
+	tests := []struct {
+		name     string
+		issuer   Config
+		verifier Config
+		wantErr  error
+	}{
+		{name: "same key", issuer: Config{SigningKey: cur}, verifier: Config{SigningKey: cur}},
+		{name: "retired key still accepted", issuer: Config{SigningKey: old}, verifier: Config{SigningKey: cur, VerifyKeys: []Key{old}}},
+		{name: "retired key dropped", issuer: Config{SigningKey: old}, verifier: Config{SigningKey: cur}, wantErr: ErrUnknownKey},
+		{name: "EdDSA public key verifies", issuer: Config{SigningKey: ed}, verifier: Config{SigningKey: cur, VerifyKeys: []Key{edPublic}}},
+		{
+			name:     "same kid other secret",
+			issuer:   Config{SigningKey: Key{ID: "new", Algorithm: AlgHS256, Secret: []byte(strings.Repeat("x", 32))}},
+			verifier: Config{SigningKey: cur},
+			wantErr:  ErrInvalidToken,
+		},
+		{
+			name:     "alg of the key wins over the token",
+			issuer:   Config{SigningKey: Key{ID: "ed", Algorithm: AlgHS256, Secret: []byte(strings.Repeat("x", 32))}},
+			verifier: Config{SigningKey: cur, VerifyKeys: []Key{edPublic}},
+			wantErr:  ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, _, err := newManager(t, tt.issuer, now).Issue(Claims{Subject: "u1"})
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			if _, err := newManager(t, tt.verifier, now).Verify(tok); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTokenManagerRejectsBadKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "no kid", cfg: Config{SigningKey: Key{Algorithm: AlgHS256, Secret: []byte("s")}}},
+		{name: "verify-only signing key", cfg: Config{SigningKey: Key{ID: "ed", Algorithm: AlgEdDSA, PublicKey: edKey("ed").PublicKey}}},
+		{name: "duplicate kid", cfg: Config{SigningKey: hsKey("a"), VerifyKeys: []Key{hsKey("a")}}},
+		{name: "verify key without kid", cfg: Config{SigningKey: hsKey("a"), VerifyKeys: []Key{{Algorithm: AlgHS256, Secret: []byte("s")}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTokenManager(tt.cfg); err == nil {
+				t.Error("NewTokenManager succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	seed := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", ed25519.SeedSize)))
+
+	tests := []struct {
+		name    string
+		spec    string
+		signing bool
+		wantAlg string
+		wantErr bool
+	}{
+		{name: "HS256", spec: "k1:HS256:" + secret, signing: true, wantAlg: AlgHS256},
+		{name: "HS256 too short", spec: "k1:HS256:" + base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "EdDSA seed", spec: "k2:EdDSA:" + seed, signing: true, wantAlg: AlgEdDSA},
+		{name: "EdDSA public key", spec: "k2:EdDSA:" + seed, wantAlg: AlgEdDSA},
+		{name: "unknown alg", spec: "k3:RS256:" + secret, wantErr: true},
+		{name: "missing kid", spec: ":HS256:" + secret, wantErr: true},
+		{name: "bad base64", spec: "k1:HS256:***", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKey(tt.spec, tt.signing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (k.Algorithm != tt.wantAlg || k.canSign() != tt.signing) {
+				t.Errorf("key = %s canSign %v", k.Algorithm, k.canSign())
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package fracturerisk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"edora/backend/internal/models"
+)
+
+var (
+	ErrUnknownSex = errors.New("fracture risk needs the patient's sex")
+	ErrAgeRange   = errors.New("patient age outside the fracture risk model range")
+)
+
+// Estimate is the 10-year fracture probability in percent.
+type Estimate struct {
+	ModelVersion         string  `json:"model_version"`
+	MajorOsteoporotic    float64 `json:"major_osteoporotic_percent"`
+	Hip                  float64 `json:"hip_percent"`
+	TreatmentRecommended bool    `json:"treatment_recommended"`
+}
+
+// Estimate combines the risk factors of a patient of sex (M or F) and age
+// with the T-score of a scan. A missing BMI contributes nothing.
+func (m *Model) Estimate(sex string, age int, tScore float64, rf *models.RiskFactors) (*Estimate, error) {
+	if sex != "M" && sex != "F" {
+		return nil, ErrUnknownSex
+	}
+	if age < m.MinAge || age > m.MaxAge {
+		return nil, fmt.Errorf("%w: %d not in %d-%d", ErrAgeRange, age, m.MinAge, m.MaxAge)
+	}
+
+	x := map[string]float64{
+		FactorPriorFracture:       indicator(rf.PriorFracture),
+		FactorParentalHipFracture: indicator(rf.ParentalHipFracture),
+		FactorCurrentSmoking:      indicator(rf.CurrentSmoking),
+		FactorGlucocorticoids:     indicator(rf.Glucocorticoids),
+		FactorRheumatoidArthritis: indicator(rf.RheumatoidArthritis),
+		FactorAlcohol:             indicator(rf.Alcohol),
+		FactorTScore:              tScore,
+	}
+	if rf.BMI != nil {
+		x[FactorBMI] = *rf.BMI - m.ReferenceBMI
+	}
+
+	e := &Estimate{
+		ModelVersion:      m.Version,
+		MajorOsteoporotic: m.Outcomes[OutcomeMajorOsteoporotic].probability(sex, float64(age)-m.ReferenceAge, x),
+		Hip:               m.Outcomes[OutcomeHip].probability(sex, float64(age)-m.ReferenceAge, x),
+	}
+	e.TreatmentRecommended = e.MajorOsteoporotic >= m.TreatmentThreshold.MajorOsteoporoticPercent ||
+		e.Hip >= m.TreatmentThreshold.HipPercent
+	return e, nil
+}
+
+// probability is the 10-year probability in percent, rounded to 0.1.
+func (o Outcome) probability(sex string, ageOffset float64, x map[string]float64) float64 {
+	lp := o.LogHazard[sex] + o.AgePerYear*ageOffset
+	for f, c := range o.Coefficients {
+		lp += c * x[f]
+	}
+	p := 1 - math.Exp(-10*math.Exp(lp))
+	return math.Round(p*1000) / 10
+}
+
+func indicator(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
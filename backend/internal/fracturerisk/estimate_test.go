@@ -0,0 +1,115 @@
+package fracturerisk
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"edora/backend/internal/models"
+)
+
+func testModel() *Model {
+	m := &Model{Version: "test-1", MinAge: 40, MaxAge: 90, ReferenceAge: 65, ReferenceBMI: 25}
+	m.TreatmentThreshold.MajorOsteoporoticPercent = 20
+	m.TreatmentThreshold.HipPercent = 3
+	m.Outcomes = map[string]Outcome{
+		OutcomeMajorOsteoporotic: {
+			LogHazard:    map[string]float64{"F": math.Log(0.01), "M": math.Log(0.005)},
+			AgePerYear:   0.05,
+			Coefficients: map[string]float64{FactorPriorFracture: math.Log(2), FactorTScore: -0.5, FactorBMI: -0.05},
+		},
+		OutcomeHip: {
+			LogHazard:    map[string]float64{"F": math.Log(0.001), "M": math.Log(0.001)},
+			AgePerYear:   0.1,
+			Coefficients: map[string]float64{FactorTScore: -1},
+		},
+	}
+	return m
+}
+
+// tenYear is 1 - exp(-10*hazard) in percent, rounded like the model.
+func tenYear(hazard float64) float64 {
+	return math.Round((1-math.Exp(-10*hazard))*1000) / 10
+}
+
+func TestEstimate(t *testing.T) {
+	bmi := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name      string
+		sex       string
+		age       int
+		tScore    float64
+		rf        models.RiskFactors
+		wantMajor float64
+		wantHip   float64
+		wantTreat bool
+		wantErr   error
+	}{
+		{name: "reference woman", sex: "F", age: 65, wantMajor: tenYear(0.01), wantHip: tenYear(0.001)},
+		{name: "reference man", sex: "M", age: 65, wantMajor: tenYear(0.005), wantHip: tenYear(0.001)},
+		{name: "prior fracture doubles the hazard", sex: "F", age: 65, rf: models.RiskFactors{PriorFracture: true}, wantMajor: tenYear(0.02), wantHip: tenYear(0.001)},
+		{
+			name: "older with low T-score", sex: "F", age: 75, tScore: -2.5,
+			wantMajor: tenYear(0.01 * math.Exp(0.5+1.25)), wantHip: tenYear(0.001 * math.Exp(1+2.5)), wantTreat: true,
+		},
+		{name: "BMI relative to the reference", sex: "F", age: 65, rf: models.RiskFactors{BMI: bmi(19)}, wantMajor: tenYear(0.01 * math.Exp(0.3)), wantHip: tenYear(0.001)},
+		{name: "unknown sex", sex: "", age: 65, wantErr: ErrUnknownSex},
+		{name: "too young", sex: "F", age: 39, wantErr: ErrAgeRange},
+		{name: "too old", sex: "M", age: 91, wantErr: ErrAgeRange},
+	}
+	m := testModel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := m.Estimate(tt.sex, tt.age, tt.tScore, &tt.rf)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Estimate error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if e.MajorOsteoporotic != tt.wantMajor || e.Hip != tt.wantHip || e.TreatmentRecommended != tt.wantTreat {
+				t.Errorf("Estimate = %.1f%%/%.1f%% treat %v, want %.1f%%/%.1f%% treat %v",
+					e.MajorOsteoporotic, e.Hip, e.TreatmentRecommended, tt.wantMajor, tt.wantHip, tt.wantTreat)
+			}
+			if e.ModelVersion != "test-1" {
+				t.Errorf("ModelVersion = %s", e.ModelVersion)
+			}
+		})
+	}
+}
+
+func TestDefaultModelIsMonotonic(t *testing.T) {
+	m, err := DefaultModel()
+	if err != nil {
+		t.Fatalf("DefaultModel: %v", err)
+	}
+	base, err := m.Estimate("F", 65, 0, &models.RiskFactors{})
+	if err != nil {
+		t.Fatalf("Estimate: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		age    int
+		tScore float64
+		rf     models.RiskFactors
+	}{
+		{name: "older", age: 80},
+		{name: "lower T-score", age: 65, tScore: -2.5},
+		{name: "prior fracture", age: 65, rf: models.RiskFactors{PriorFracture: true}},
+		{name: "parental hip fracture", age: 65, rf: models.RiskFactors{ParentalHipFracture: true}},
+		{name: "glucocorticoids", age: 65, rf: models.RiskFactors{Glucocorticoids: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := m.Estimate("F", tt.age, tt.tScore, &tt.rf)
+			if err != nil {
+				t.Fatalf("Estimate: %v", err)
+			}
+			if e.MajorOsteoporotic <= base.MajorOsteoporotic || e.Hip < base.Hip {
+				t.Errorf("risk %.1f%%/%.1f%% not above baseline %.1f%%/%.1f%%", e.MajorOsteoporotic, e.Hip, base.MajorOsteoporotic, base.Hip)
+			}
+		})
+	}
+}
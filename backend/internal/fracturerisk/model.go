@@ -0,0 +1,129 @@
+// Package fracturerisk estimates the 10-year probability of a major
+// osteoporotic and of a hip fracture from clinical risk factors and the
+// T-score of a scan.
+//
+// The model is an exponential hazard: the yearly hazard is
+// exp(log_hazard[sex] + age_per_year*(age-reference_age) + sum(coefficient
+// * factor)) and the 10-year probability is 1 - exp(-10 * hazard). Boolean
+// factors count as 1 when present, bmi as the difference from
+// reference_bmi and t_score as is. Competing mortality is ignored.
+package fracturerisk
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+//go:embed default_model.json
+var defaultModel []byte
+
+// Factors the model has coefficients for.
+const (
+	FactorPriorFracture       = "prior_fracture"
+	FactorParentalHipFracture = "parental_hip_fracture"
+	FactorCurrentSmoking      = "current_smoking"
+	FactorGlucocorticoids     = "glucocorticoids"
+	FactorRheumatoidArthritis = "rheumatoid_arthritis"
+	FactorAlcohol             = "alcohol"
+	FactorBMI                 = "bmi"
+	FactorTScore              = "t_score"
+)
+
+// Outcomes the model predicts.
+const (
+	OutcomeMajorOsteoporotic = "major_osteoporotic"
+	OutcomeHip               = "hip"
+)
+
+// Model is a versioned set of coefficients. Every estimate records Version.
+type Model struct {
+	Version     string `json:"version"`
+	Description string `json:"description"`
+
+	// Patients outside [MinAge, MaxAge] are not estimated.
+	MinAge       int     `json:"min_age"`
+	MaxAge       int     `json:"max_age"`
+	ReferenceAge float64 `json:"reference_age"`
+	ReferenceBMI float64 `json:"reference_bmi"`
+
+	TreatmentThreshold struct {
+		MajorOsteoporoticPercent float64 `json:"major_osteoporotic_percent"`
+		HipPercent               float64 `json:"hip_percent"`
+	} `json:"treatment_threshold"`
+
+	Outcomes map[string]Outcome `json:"outcomes"`
+}
+
+// Outcome holds the hazard coefficients of one fracture type.
+type Outcome struct {
+	// LogHazard is the log yearly hazard at ReferenceAge by sex (M, F) with
+	// no risk factors, BMI at ReferenceBMI and a T-score of 0.
+	LogHazard    map[string]float64 `json:"log_hazard"`
+	AgePerYear   float64            `json:"age_per_year"`
+	Coefficients map[string]float64 `json:"coefficients"`
+}
+
+// DefaultModel returns the model built into the binary.
+func DefaultModel() (*Model, error) {
+	return ParseModel(defaultModel)
+}
+
+// LoadModel reads a model from a JSON file.
+func LoadModel(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseModel(data)
+}
+
+func ParseModel(data []byte) (*Model, error) {
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("fracture risk model: %w", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("fracture risk model %q: %w", m.Version, err)
+	}
+	return &m, nil
+}
+
+func (m *Model) validate() error {
+	switch {
+	case m.Version == "":
+		return errors.New("version required")
+	case m.MinAge <= 0 || m.MinAge > m.MaxAge:
+		return errors.New("min_age must be positive and not above max_age")
+	case m.ReferenceBMI <= 0:
+		return errors.New("reference_bmi must be positive")
+	case m.TreatmentThreshold.MajorOsteoporoticPercent <= 0 || m.TreatmentThreshold.HipPercent <= 0:
+		return errors.New("treatment thresholds must be positive")
+	}
+	for _, name := range []string{OutcomeMajorOsteoporotic, OutcomeHip} {
+		o, ok := m.Outcomes[name]
+		if !ok {
+			return fmt.Errorf("outcome %q missing", name)
+		}
+		if _, ok := o.LogHazard["M"]; !ok {
+			return fmt.Errorf("outcome %q: log_hazard for M missing", name)
+		}
+		if _, ok := o.LogHazard["F"]; !ok {
+			return fmt.Errorf("outcome %q: log_hazard for F missing", name)
+		}
+		for f := range o.Coefficients {
+			switch f {
+			case FactorPriorFracture, FactorParentalHipFracture, FactorCurrentSmoking, FactorGlucocorticoids,
+				FactorRheumatoidArthritis, FactorAlcohol, FactorBMI, FactorTScore:
+			default:
+				return fmt.Errorf("outcome %q: unknown factor %q", name, f)
+			}
+		}
+	}
+	if len(m.Outcomes) != 2 {
+		return errors.New("unknown outcome")
+	}
+	return nil
+}
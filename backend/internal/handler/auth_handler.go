@@ -2,25 +2,25 @@ package handler
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
-	"fmt"
+	"errors"
 	"log"
+	"math"
+	"strconv"
+	"strings"
 
 	"edora/backend/internal/models"
 	"edora/backend/internal/repository"
+	"edora/backend/internal/service"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	sessions map[string]models.User // token -> user
-	users    repository.UserRepo
+	svc *service.AuthService
 }
 
-func NewAuthHandler(users repository.UserRepo) *AuthHandler {
-	return &AuthHandler{sessions: make(map[string]models.User), users: users}
+func NewAuthHandler(svc *service.AuthService) *AuthHandler {
+	return &AuthHandler{svc: svc}
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
@@ -29,49 +29,322 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
 	}
 
-	// Lookup user from DB
-	u, err := h.users.GetByUsername(context.Background(), body.Username)
+	u, err := h.svc.Authenticate(context.Background(), body.Username, body.Password, c.IP())
+	var locked *service.LockedError
+	if errors.As(err, &locked) {
+		return tooManyAttempts(c, locked)
+	}
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		log.Printf("login failed from %s", c.IP())
+		return c.Status(401).JSON(fiber.Map{"error": "invalid credentials"})
+	}
+	if errors.Is(err, service.ErrAccountDisabled) {
+		return c.Status(403).JSON(fiber.Map{"error": "account disabled"})
+	}
 	if err != nil {
 		log.Printf("login lookup error: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "internal"})
 	}
-	if u == nil {
-		log.Printf("login failed for %s: user not found", body.Username)
-		return c.Status(401).JSON(fiber.Map{"error": "invalid credentials"})
+
+	// second step required: hand out an mfa_token for /auth/mfa/verify
+	if u.TOTPEnabled {
+		mfaToken, exp, err := h.svc.StartMFAChallenge(u)
+		if err != nil {
+			log.Printf("login mfa token error: %v", err)
+			return c.Status(500).JSON(fiber.Map{"error": "internal"})
+		}
+		return c.JSON(fiber.Map{"mfa_required": true, "mfa_token": mfaToken, "expires_at": exp.UTC()})
 	}
 
-	// Verify password using bcrypt
-	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(body.Password)); err != nil {
-		log.Printf("login failed for %s: bad password", body.Username)
-		return c.Status(401).JSON(fiber.Map{"error": "invalid credentials"})
+	pair, err := h.svc.StartSession(context.Background(), u, c.Get(fiber.HeaderUserAgent), c.IP())
+	if err != nil {
+		log.Printf("login session error: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "internal"})
+	}
+	return c.JSON(pair)
+}
+
+type mfaVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// VerifyMFA is the second login step: mfa_token plus a TOTP or recovery code.
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var body mfaVerifyRequest
+	if err := c.BodyParser(&body); err != nil || body.MFAToken == "" || (body.Code == "" && body.RecoveryCode == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "mfa_token and code or recovery_code required"})
+	}
+
+	pair, err := h.svc.VerifyMFA(context.Background(), body.MFAToken, body.Code, body.RecoveryCode, c.Get(fiber.HeaderUserAgent), c.IP())
+	var locked *service.LockedError
+	switch {
+	case errors.As(err, &locked):
+		return tooManyAttempts(c, locked)
+	case errors.Is(err, service.ErrInvalidMFAToken), errors.Is(err, service.ErrInvalidMFACode):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrAccountDisabled):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(pair)
+}
+
+// EnrollTOTP starts TOTP enrolment for the logged-in user and returns the
+// secret and otpauth URI to render as a QR code.
+func (h *AuthHandler) EnrollTOTP(c *fiber.Ctx) error {
+	enr, err := h.svc.EnrollTOTP(context.Background(), CurrentUser(c).ID)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	return c.JSON(enr)
+}
+
+// ActivateTOTP confirms enrolment with the first code from the app.
+func (h *AuthHandler) ActivateTOTP(c *fiber.Ctx) error {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code required"})
+	}
+
+	sid, _ := h.svc.SessionFromAccessToken(bearerToken(c))
+	codes, pair, err := h.svc.ActivateTOTP(context.Background(), CurrentUser(c).ID, sid, body.Code)
+	if err != nil {
+		return mfaError(c, err)
 	}
+	return c.JSON(fiber.Map{"recovery_codes": codes, "tokens": pair})
+}
 
-	// generate token
-	b := make([]byte, 16)
-	_, _ = rand.Read(b)
-	token := hex.EncodeToString(b)
+// RegenerateRecoveryCodes replaces the recovery codes of the logged-in user.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code required"})
+	}
 
-	// store user without password in session map
-	sessionUser := *u
-	sessionUser.Password = ""
-	h.sessions[token] = sessionUser
+	codes, err := h.svc.RegenerateRecoveryCodes(context.Background(), CurrentUser(c).ID, body.Code)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+// ResetTOTP removes TOTP from a user account (admin).
+func (h *AuthHandler) ResetTOTP(c *fiber.Ctx) error {
+	if err := h.svc.ResetTOTP(context.Background(), c.Params("id")); err != nil {
+		return mfaError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
 
-	return c.JSON(fiber.Map{"token": token, "role": u.Role})
+func mfaError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidMFACode):
+		status = fiber.StatusUnauthorized
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnrolled):
+		status = fiber.StatusConflict
+	case errors.Is(err, repository.ErrNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
 }
 
+func tooManyAttempts(c *fiber.Ctx, locked *service.LockedError) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": locked.Error()})
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword changes the password of the logged-in user and returns a
+// new token pair. Other sessions of the user are revoked.
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	var body changePasswordRequest
+	if err := c.BodyParser(&body); err != nil || body.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "current_password and new_password required"})
+	}
+
+	sid, _ := h.svc.SessionFromAccessToken(bearerToken(c))
+	pair, err := h.svc.ChangePassword(context.Background(), CurrentUser(c).ID, sid, body.CurrentPassword, body.NewPassword)
+	if err != nil {
+		return passwordError(c, err)
+	}
+	return c.JSON(pair)
+}
+
+// IssuePasswordReset creates a one-time reset token for a user (admin). The
+// admin hands the token to the user out of band.
+func (h *AuthHandler) IssuePasswordReset(c *fiber.Ctx) error {
+	token, exp, err := h.svc.IssuePasswordReset(context.Background(), CurrentUser(c).ID, c.Params("id"))
+	if err != nil {
+		return passwordError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"reset_token": token, "expires_at": exp})
+}
+
+// ResetPassword sets a new password with a reset token (public).
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var body struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Token == "" || body.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token and new_password required"})
+	}
+
+	if err := h.svc.ResetPassword(context.Background(), body.Token, body.NewPassword); err != nil {
+		return passwordError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func passwordError(c *fiber.Ctx, err error) error {
+	var policy *service.PasswordPolicyError
+	if errors.As(err, &policy) {
+		return passwordPolicyError(c, policy)
+	}
+
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrWrongPassword), errors.Is(err, service.ErrInvalidResetToken):
+		status = fiber.StatusUnauthorized
+	case errors.Is(err, service.ErrPasswordReused):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, repository.ErrNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh exchanges a refresh token for a new access/refresh token pair.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var body refreshRequest
+	if err := c.BodyParser(&body); err != nil || body.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "refresh_token required"})
+	}
+
+	pair, err := h.svc.Refresh(context.Background(), body.RefreshToken)
+	switch {
+	case errors.Is(err, service.ErrInvalidRefreshToken),
+		errors.Is(err, service.ErrRefreshTokenReused),
+		errors.Is(err, service.ErrSessionRevoked),
+		errors.Is(err, service.ErrAccountDisabled):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(pair)
+}
+
+// Logout revokes the session of the given refresh token, or of the bearer
+// access token when no refresh token is posted.
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var body refreshRequest
+	_ = c.BodyParser(&body)
+
+	if body.RefreshToken != "" {
+		err := h.svc.Logout(context.Background(), body.RefreshToken)
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	sid, err := h.svc.SessionFromAccessToken(bearerToken(c))
+	if err != nil || sid == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if err := h.svc.RevokeSession(context.Background(), sid, "logout"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListSessions lists the sessions of a user (admin).
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	sessions, err := h.svc.ListSessions(context.Background(), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	return c.JSON(sessions)
+}
+
+// RevokeSession kills a single session, e.g. for a stolen tablet (admin).
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	if err := h.svc.RevokeSession(context.Background(), c.Params("id"), "revoked by admin"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RevokeUserSessions kills every session of a user (admin).
+func (h *AuthHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	if err := h.svc.RevokeUserSessions(context.Background(), c.Params("id"), "revoked by admin"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Unlock clears the login lockout of a user (admin). An optional "ip" in
+// the body also clears that client IP.
+func (h *AuthHandler) Unlock(c *fiber.Ctx) error {
+	var body struct {
+		IP string `json:"ip"`
+	}
+	_ = c.BodyParser(&body)
+
+	err := h.svc.Unlock(context.Background(), c.Params("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if body.IP != "" {
+		h.svc.UnlockIP(body.IP)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AuthenticatedUser verifies the bearer token and returns the user it was
+// issued to, or nil when the request carries no valid token.
 func (h *AuthHandler) AuthenticatedUser(c *fiber.Ctx) *models.User {
-	auth := c.Get("Authorization")
-	if auth == "" {
+	token := bearerToken(c)
+	if token == "" {
 		return nil
 	}
-	// expect 'Bearer <token>'
-	var token string
-	if _, err := fmt.Sscanf(auth, "Bearer %s", &token); err != nil {
+	u, err := h.svc.VerifyAccessToken(context.Background(), token)
+	if err != nil {
 		return nil
 	}
-	u, ok := h.sessions[token]
+	return u
+}
+
+// bearerToken extracts the token from an 'Authorization: Bearer <token>' header.
+func bearerToken(c *fiber.Ctx) string {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
 	if !ok {
-		return nil
+		return ""
 	}
-	return &u
+	return strings.TrimSpace(token)
 }
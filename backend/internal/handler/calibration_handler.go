@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+	"edora/backend/internal/service"
+)
+
+type CalibrationHandler struct {
+	svc *service.CalibrationService
+}
+
+func NewCalibrationHandler(s *service.CalibrationService) *CalibrationHandler {
+	return &CalibrationHandler{svc: s}
+}
+
+type calibrationRequest struct {
+	PerformedAt      time.Time `json:"performed_at"`
+	Technician       string    `json:"technician"`
+	PhantomReference []float64 `json:"phantom_reference"`
+	MeasuredValues   []float64 `json:"measured_values"`
+	// Passed may be omitted to let the server judge the phantom values.
+	Passed *bool  `json:"passed"`
+	Notes  string `json:"notes"`
+}
+
+// Record logs a phantom calibration run for a device.
+func (h *CalibrationHandler) Record(c *fiber.Ctx) error {
+	var req calibrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+
+	cal := models.DeviceCalibration{
+		DeviceID:         c.Params("id"),
+		PerformedAt:      req.PerformedAt,
+		Technician:       req.Technician,
+		PhantomReference: req.PhantomReference,
+		MeasuredValues:   req.MeasuredValues,
+		Notes:            req.Notes,
+	}
+	if u := CurrentUser(c); u != nil {
+		cal.CreatedBy = u.ID
+	}
+	if err := h.svc.Record(context.Background(), &cal, req.Passed); err != nil {
+		return calibrationError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(cal)
+}
+
+// List returns the calibration history of a device, most recent first.
+func (h *CalibrationHandler) List(c *fiber.Ctx) error {
+	cals, err := h.svc.List(context.Background(), c.Params("id"))
+	if err != nil {
+		return calibrationError(c, err)
+	}
+	if cals == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "device not found"})
+	}
+	return c.JSON(cals)
+}
+
+// GetPolicy returns the effective calibration policy of a facility.
+func (h *CalibrationHandler) GetPolicy(c *fiber.Ctx) error {
+	p, err := h.svc.Policy(context.Background(), c.Params("facility"))
+	if err != nil {
+		return calibrationError(c, err)
+	}
+	return c.JSON(p)
+}
+
+// SetPolicy sets the calibration interval and enforcement of a facility.
+func (h *CalibrationHandler) SetPolicy(c *fiber.Ctx) error {
+	var p models.CalibrationPolicy
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+	p.Facility = c.Params("facility")
+	if err := h.svc.SetPolicy(context.Background(), p); err != nil {
+		return calibrationError(c, err)
+	}
+	return c.JSON(p)
+}
+
+func calibrationError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidCalibration), errors.Is(err, service.ErrInvalidPolicy):
+		status = fiber.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
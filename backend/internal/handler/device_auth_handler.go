@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"edora/backend/internal/auth"
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+	"edora/backend/internal/service"
+)
+
+// localsDevice is the fiber.Ctx Locals key holding the authenticated *models.Device.
+const localsDevice = "device"
+
+type DeviceAuthHandler struct {
+	svc *service.DeviceAuthService
+}
+
+func NewDeviceAuthHandler(s *service.DeviceAuthService) *DeviceAuthHandler {
+	return &DeviceAuthHandler{svc: s}
+}
+
+// RequireDevice only lets through requests signed by a provisioned device.
+// See auth.DeviceSignature for the signing scheme; the path is the full
+// request path, e.g. /api/v1/sync/reading.
+func (h *DeviceAuthHandler) RequireDevice() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dev, err := h.svc.Authenticate(context.Background(), service.SignedRequest{
+			Serial:    c.Get(auth.HeaderDeviceSerial),
+			Timestamp: c.Get(auth.HeaderDeviceTimestamp),
+			Nonce:     c.Get(auth.HeaderDeviceNonce),
+			Signature: c.Get(auth.HeaderDeviceSignature),
+			Method:    c.Method(),
+			Path:      c.Path(),
+			Body:      c.Body(),
+		})
+		switch {
+		case errors.Is(err, service.ErrDeviceUnauthorized),
+			errors.Is(err, service.ErrStaleTimestamp),
+			errors.Is(err, service.ErrNonceReused):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Locals(localsDevice, dev)
+		return c.Next()
+	}
+}
+
+// RotateCredentials issues a new secret for a device (admin). The response is
+// the only time the secret is shown.
+func (h *DeviceAuthHandler) RotateCredentials(c *fiber.Ctx) error {
+	serial := c.Params("serial")
+	secret, err := h.svc.RotateSecret(context.Background(), serial)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "device not registered"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"device_serial": serial, "secret": secret})
+}
+
+// CurrentDevice returns the device stored by RequireDevice, or nil.
+func CurrentDevice(c *fiber.Ctx) *models.Device {
+	d, _ := c.Locals(localsDevice).(*models.Device)
+	return d
+}
@@ -2,27 +2,268 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
 	"edora/backend/internal/service"
 )
 
 type DeviceHandler struct {
 	svc *service.DeviceService
+	// offlineAfter is the presence monitor's threshold, the default of
+	// List's offline_after so both agree on which devices are offline.
+	offlineAfter time.Duration
 }
 
-func NewDeviceHandler(s *service.DeviceService) *DeviceHandler {
-	return &DeviceHandler{svc: s}
+func NewDeviceHandler(s *service.DeviceService, offlineAfter time.Duration) *DeviceHandler {
+	return &DeviceHandler{svc: s, offlineAfter: offlineAfter}
 }
 
-// List returns a simple summary: number of active devices in the last 5 minutes.
+// List returns a page of devices.
+//
+// Query params: page (1), page_size (20, max 100), status (comma separated),
+// sort (last_seen or -last_seen, default -last_seen), window (online if seen
+// within, default 5m or offline_after if shorter) and offline_after (stale
+// until, default DEVICE_OFFLINE_AFTER).
 func (h *DeviceHandler) List(c *fiber.Ctx) error {
-	since := 5 * time.Minute
-	cnt, err := h.svc.CountActive(context.Background(), since)
+	q := service.DeviceListQuery{
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", 20),
+	}
+	if q.Page < 1 || q.PageSize < 1 || q.PageSize > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "page must be >= 1 and page_size between 1 and 100"})
+	}
+
+	if st := c.Query("status"); st != "" {
+		for _, s := range strings.Split(st, ",") {
+			s = strings.TrimSpace(s)
+			if !slices.Contains([]string{models.DeviceOnline, models.DeviceOffline, models.DeviceMaintenance, models.DeviceDecommissioned}, s) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown status: " + s})
+			}
+			q.Statuses = append(q.Statuses, s)
+		}
+	}
+
+	switch c.Query("sort", "-last_seen") {
+	case "-last_seen":
+	case "last_seen":
+		q.LastSeenAsc = true
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "sort must be last_seen or -last_seen"})
+	}
+
+	var err error
+	if q.OfflineAfter, err = queryDuration(c, "offline_after", h.offlineAfter); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if q.Window, err = queryDuration(c, "window", min(5*time.Minute, q.OfflineAfter)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if q.OfflineAfter < q.Window {
+		q.OfflineAfter = q.Window
+	}
+
+	list, err := h.svc.ListDevices(context.Background(), q)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// queryDuration parses a Go duration query parameter such as "5m".
+func queryDuration(c *fiber.Ctx, key string, def time.Duration) (time.Duration, error) {
+	v := c.Query(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, errors.New(key + " must be a positive duration like 5m")
+	}
+	return d, nil
+}
+
+type deviceRequest struct {
+	SerialNumber     string `json:"serial_number"`
+	Name             string `json:"name"`
+	Facility         string `json:"facility"`
+	HardwareRevision int    `json:"hardware_revision"`
+	// LSC is only used by Update.
+	LSC *float64 `json:"lsc_bmd"`
+}
+
+// Register creates a device and returns it with its signing secret. The
+// secret is not shown again; use POST /devices/:serial/credentials to rotate.
+func (h *DeviceHandler) Register(c *fiber.Ctx) error {
+	var req deviceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+
+	dev := models.Device{SerialNumber: req.SerialNumber, Name: req.Name, Facility: req.Facility, HardwareRevision: req.HardwareRevision}
+	secret, err := h.svc.Register(context.Background(), &dev)
+	if err != nil {
+		return deviceError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"device": dev, "secret": secret})
+}
+
+// Get returns one device with its last seen time and the number of readings
+// in the last ?recent_hours (default 24).
+func (h *DeviceHandler) Get(c *fiber.Ctx) error {
+	hours := c.QueryInt("recent_hours", 24)
+	if hours <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "recent_hours must be positive"})
+	}
+
+	dev, err := h.svc.Get(context.Background(), c.Params("id"), time.Duration(hours)*time.Hour)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.JSON(fiber.Map{"active_count": cnt})
+	if dev == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "device not found"})
+	}
+	return c.JSON(dev)
+}
+
+// Uptime returns the uptime percentage of a device over ?window (default 168h).
+func (h *DeviceHandler) Uptime(c *fiber.Ctx) error {
+	window, err := queryDuration(c, "window", 7*24*time.Hour)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	up, err := h.svc.Uptime(context.Background(), c.Params("id"), window)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if up == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "device not found"})
+	}
+	return c.JSON(up)
+}
+
+type heartbeatRequest struct {
+	Serial           string   `json:"serial"`
+	FirmwareVersion  string   `json:"firmware_version"`
+	HardwareRevision *int     `json:"hardware_revision"`
+	BatteryLevel     *float64 `json:"battery_level"`
+	Temperature      *float64 `json:"temperature"`
+	FreeStorage      *int64   `json:"free_storage"` // bytes
+}
+
+// Heartbeat is called periodically by a signed device, independent of
+// reading uploads, to report it is alive and send health telemetry.
+func (h *DeviceHandler) Heartbeat(c *fiber.Ctx) error {
+	var req heartbeatRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	dev := CurrentDevice(c)
+	if req.Serial != "" && req.Serial != dev.SerialNumber {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "serial does not match signing device"})
+	}
+
+	t := models.DeviceTelemetry{
+		FirmwareVersion:  req.FirmwareVersion,
+		BatteryLevel:     req.BatteryLevel,
+		Temperature:      req.Temperature,
+		FreeStorageBytes: req.FreeStorage,
+		HardwareRevision: req.HardwareRevision,
+	}
+	if err := h.svc.Heartbeat(context.Background(), dev, &t); err != nil {
+		if errors.Is(err, service.ErrInvalidTelemetry) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	// tell the scanner whether it is in maintenance or online again
+	status := dev.Status
+	if dev.Accepting() {
+		status = models.DeviceOnline
+	}
+	return c.JSON(fiber.Map{"status": status, "server_time": t.RecordedAt})
+}
+
+// Telemetry returns heartbeat samples of a device for charting.
+// Query params: window (default 24h), limit (default 500, max 5000).
+func (h *DeviceHandler) Telemetry(c *fiber.Ctx) error {
+	window, err := queryDuration(c, "window", 24*time.Hour)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	limit := c.QueryInt("limit", 500)
+	if limit < 1 || limit > 5000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "limit must be between 1 and 5000"})
+	}
+
+	samples, err := h.svc.Telemetry(context.Background(), c.Params("id"), window, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if samples == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "device not found"})
+	}
+	return c.JSON(samples)
+}
+
+// Update changes name and facility of a device, and its least significant
+// BMD change when lsc_bmd is given.
+func (h *DeviceHandler) Update(c *fiber.Ctx) error {
+	var req deviceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+
+	dev := models.Device{ID: c.Params("id"), Name: req.Name, Facility: req.Facility, LSC: req.LSC}
+	if err := h.svc.Update(context.Background(), &dev); err != nil {
+		return deviceError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// StartMaintenance puts a device into maintenance.
+func (h *DeviceHandler) StartMaintenance(c *fiber.Ctx) error {
+	if err := h.svc.SetMaintenance(context.Background(), c.Params("id"), true); err != nil {
+		return deviceError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// EndMaintenance takes a device out of maintenance.
+func (h *DeviceHandler) EndMaintenance(c *fiber.Ctx) error {
+	if err := h.svc.SetMaintenance(context.Background(), c.Params("id"), false); err != nil {
+		return deviceError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Decommission retires a device permanently.
+func (h *DeviceHandler) Decommission(c *fiber.Ctx) error {
+	if err := h.svc.Decommission(context.Background(), c.Params("id")); err != nil {
+		return deviceError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func deviceError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrSerialRequired),
+		errors.Is(err, service.ErrInvalidLSC):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, repository.ErrSerialTaken):
+		status = fiber.StatusConflict
+	case errors.Is(err, repository.ErrNotFound):
+		// missing or already decommissioned
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
 }
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+	"edora/backend/internal/service"
+)
+
+type FirmwareHandler struct {
+	svc *service.FirmwareService
+}
+
+func NewFirmwareHandler(s *service.FirmwareService) *FirmwareHandler {
+	return &FirmwareHandler{svc: s}
+}
+
+// Publish uploads a firmware release as multipart form: file, version,
+// release_notes, min_hardware_revision, max_hardware_revision,
+// rollout_percent (default 0) and optionally checksum_sha256 to verify the
+// upload.
+func (h *FirmwareHandler) Publish(c *fiber.Ctx) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file required"})
+	}
+
+	rel := models.FirmwareRelease{
+		Version:      c.FormValue("version"),
+		ReleaseNotes: c.FormValue("release_notes"),
+	}
+	if rel.MinHardwareRevision, err = formInt(c, "min_hardware_revision"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if rel.MaxHardwareRevision, err = formInt(c, "max_hardware_revision"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	rollout, err := formInt(c, "rollout_percent")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if rollout != nil {
+		rel.RolloutPercent = *rollout
+	}
+	if u := CurrentUser(c); u != nil {
+		rel.CreatedBy = u.ID
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer f.Close()
+
+	if err := h.svc.Publish(context.Background(), &rel, f, c.FormValue("checksum_sha256")); err != nil {
+		return firmwareError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(rel)
+}
+
+// formInt reads an optional integer form field.
+func formInt(c *fiber.Ctx, key string) (*int, error) {
+	v := c.FormValue(key)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, errors.New(key + " must be an integer")
+	}
+	return &n, nil
+}
+
+// List returns all firmware releases, newest first.
+func (h *FirmwareHandler) List(c *fiber.Ctx) error {
+	rels, err := h.svc.List(context.Background())
+	if err != nil {
+		return firmwareError(c, err)
+	}
+	return c.JSON(rels)
+}
+
+// SetRollout changes the staged rollout percentage of a release.
+func (h *FirmwareHandler) SetRollout(c *fiber.Ctx) error {
+	var req struct {
+		RolloutPercent *int `json:"rollout_percent"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.RolloutPercent == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "rollout_percent required"})
+	}
+	if err := h.svc.SetRollout(context.Background(), c.Params("version"), *req.RolloutPercent); err != nil {
+		return firmwareError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Progress lists the devices a release was offered to and how far each got.
+func (h *FirmwareHandler) Progress(c *fiber.Ctx) error {
+	progress, err := h.svc.Progress(context.Background(), c.Params("version"))
+	if err != nil {
+		return firmwareError(c, err)
+	}
+	if progress == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "firmware not found"})
+	}
+	return c.JSON(progress)
+}
+
+// DeviceUpdates lists the firmware releases offered to one device.
+func (h *FirmwareHandler) DeviceUpdates(c *fiber.Ctx) error {
+	updates, err := h.svc.DeviceUpdates(context.Background(), c.Params("id"))
+	if err != nil {
+		return firmwareError(c, err)
+	}
+	return c.JSON(updates)
+}
+
+// Manifest is polled by a signed device to learn whether an update is
+// available for it.
+func (h *FirmwareHandler) Manifest(c *fiber.Ctx) error {
+	dev := CurrentDevice(c)
+	if c.Params("serial") != dev.SerialNumber {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "serial does not match signing device"})
+	}
+
+	m, err := h.svc.Manifest(context.Background(), dev)
+	if err != nil {
+		return firmwareError(c, err)
+	}
+	if m.UpdateAvailable {
+		m.DownloadURL = c.Path() + "/" + m.Version + "/binary"
+	}
+	return c.JSON(m)
+}
+
+// Download serves a firmware binary to a signed device that is eligible for
+// it. The checksum is repeated in a header for verification.
+func (h *FirmwareHandler) Download(c *fiber.Ctx) error {
+	dev := CurrentDevice(c)
+	if c.Params("serial") != dev.SerialNumber {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "serial does not match signing device"})
+	}
+
+	path, rel, err := h.svc.Binary(context.Background(), dev, c.Params("version"))
+	if err != nil {
+		return firmwareError(c, err)
+	}
+	c.Set("X-Checksum-SHA256", rel.ChecksumSHA256)
+	return c.Download(path, rel.FileName)
+}
+
+func firmwareError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidFirmware), errors.Is(err, service.ErrChecksumMismatch):
+		status = fiber.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrVersionTaken):
+		status = fiber.StatusConflict
+	case errors.Is(err, service.ErrFirmwareNotFound), errors.Is(err, repository.ErrNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"edora/backend/internal/models"
+)
+
+// localsUser is the fiber.Ctx Locals key holding the authenticated *models.User.
+const localsUser = "user"
+
+// RequireAuth rejects requests without a valid bearer token and stores the
+// authenticated user in the request context for later handlers. Users who
+// must change their password, or whose role requires TOTP but who have not
+// enrolled yet, get 403 until they do.
+func (h *AuthHandler) RequireAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := h.AuthenticatedUser(c)
+		if u == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		if u.MustChangePassword {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "password change required"})
+		}
+		if h.svc.MFASetupRequired(u) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "mfa enrollment required"})
+		}
+		c.Locals(localsUser, u)
+		return c.Next()
+	}
+}
+
+// RequireToken is RequireAuth without the account gates. It is used on the
+// self-service routes a gated user needs to get out of the gate.
+func (h *AuthHandler) RequireToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := h.AuthenticatedUser(c)
+		if u == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		c.Locals(localsUser, u)
+		return c.Next()
+	}
+}
+
+// RequireRole allows the request through only when the authenticated user has
+// one of the given roles. It must run after RequireAuth.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := CurrentUser(c)
+		if u == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		if !slices.Contains(roles, u.Role) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+		}
+		return c.Next()
+	}
+}
+
+// CurrentUser returns the user stored by RequireAuth, or nil.
+func CurrentUser(c *fiber.Ctx) *models.User {
+	u, _ := c.Locals(localsUser).(*models.User)
+	return u
+}
+
+// LimitBody caps request bodies at maxBytes, or at routes["METHOD /path"]
+// for the routes listed there, and rejects larger ones with 413. The app
+// runs with StreamRequestBody, so the server does not buffer bodies itself:
+// LimitBody reads at most one byte past the limit before giving up, and the
+// handlers see the buffered body as usual.
+func LimitBody(maxBytes int, routes map[string]int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := maxBytes
+		if n, ok := routes[c.Method()+" "+c.Path()]; ok {
+			limit = n
+		}
+		req := c.Request()
+		if req.Header.ContentLength() > limit {
+			return bodyTooLarge(c, limit)
+		}
+		if !req.IsBodyStream() {
+			if len(req.Body()) > limit {
+				return bodyTooLarge(c, limit)
+			}
+			return c.Next()
+		}
+		body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(limit)+1))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot read request body: " + err.Error()})
+		}
+		if len(body) > limit {
+			return bodyTooLarge(c, limit)
+		}
+		req.SetBody(body)
+		return c.Next()
+	}
+}
+
+func bodyTooLarge(c *fiber.Ctx, limit int) error {
+	// the rest of the body is never read, so the connection cannot be reused
+	c.Set(fiber.HeaderConnection, "close")
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "request body too large, limit is " + strconv.Itoa(limit) + " bytes"})
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		size    int
+		chunked bool
+		// wantStatus is 200 when the handler saw the whole body
+		wantStatus int
+	}{
+		{name: "under the default", method: fiber.MethodPost, path: "/small", size: 10, wantStatus: fiber.StatusOK},
+		{name: "over the default", method: fiber.MethodPost, path: "/small", size: 11, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "over the default without Content-Length", method: fiber.MethodPost, path: "/small", size: 11, chunked: true, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "under the default without Content-Length", method: fiber.MethodPost, path: "/small", size: 10, chunked: true, wantStatus: fiber.StatusOK},
+		{name: "route limit lifts the default", method: fiber.MethodPost, path: "/big", size: 100, wantStatus: fiber.StatusOK},
+		{name: "over the route limit", method: fiber.MethodPost, path: "/big", size: 101, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "over the route limit without Content-Length", method: fiber.MethodPost, path: "/big", size: 101, chunked: true, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "route limit is per method", method: fiber.MethodPut, path: "/big", size: 11, wantStatus: fiber.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{StreamRequestBody: true})
+			app.Use(LimitBody(10, map[string]int{"POST /big": 100}))
+			app.All("/*", func(c *fiber.Ctx) error { return c.SendString(strconv.Itoa(len(c.Body()))) })
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(strings.Repeat("x", tt.size)))
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			status, body := respond(t, app, req)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", status, body, tt.wantStatus)
+			}
+			if status == fiber.StatusOK && body != strconv.Itoa(tt.size) {
+				t.Errorf("handler saw %s bytes, want %d", body, tt.size)
+			}
+		})
+	}
+}
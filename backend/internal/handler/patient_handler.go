@@ -2,11 +2,13 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
 	"edora/backend/internal/service"
 )
 
@@ -114,7 +116,49 @@ func (h *PatientHandler) Delete(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "patient id required"})
 	}
 	if err := h.svc.DeletePatient(context.Background(), id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return patientError(c, err)
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// GetRiskFactors returns the fracture risk questionnaire of a patient; 404
+// when it was never filled in.
+func (h *PatientHandler) GetRiskFactors(c *fiber.Ctx) error {
+	rf, err := h.svc.RiskFactors(context.Background(), c.Params("id"))
+	if err != nil {
+		return patientError(c, err)
+	}
+	if rf == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "risk factors not recorded"})
+	}
+	return c.JSON(rf)
+}
+
+// SetRiskFactors replaces the fracture risk questionnaire of a patient and
+// recomputes the estimate of their unsigned medical records; signed records
+// keep theirs.
+func (h *PatientHandler) SetRiskFactors(c *fiber.Ctx) error {
+	var rf models.RiskFactors
+	if err := c.BodyParser(&rf); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+	rf.PatientID = c.Params("id")
+	rf.UpdatedBy = CurrentUser(c).ID
+	if err := h.svc.SaveRiskFactors(context.Background(), &rf); err != nil {
+		return patientError(c, err)
+	}
+	return c.JSON(rf)
+}
+
+func patientError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidRiskFactors):
+		status = fiber.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrHasSignedRecords):
+		status = fiber.StatusConflict
+	case errors.Is(err, repository.ErrNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/service"
+)
+
+// maxBatchItems caps the number of readings in one batch request.
+const maxBatchItems = 1000
+
+// SyncReadingsBatch uploads an offline backlog from a signed device. The
+// body is a JSON array of sync payloads (v1 or v2, per item), or NDJSON (one payload per line)
+// when Content-Type is application/x-ndjson. Every item is judged on its
+// own; the response lists per item whether it was created, a duplicate of
+// an earlier sync or rejected, and why; index is the position in the array
+// or among the non-empty NDJSON lines. Other content types get 415.
+func (h *ReadingHandler) SyncReadingsBatch(c *fiber.Ctx) error {
+	if !jsonBody(c) && !ndjsonBody(c) {
+		return unsupportedMediaType(c, fiber.MIMEApplicationJSON, mediaTypeV2, mediaTypeNDJSON)
+	}
+	items, err := splitBatch(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no readings"})
+	}
+	if len(items) > maxBatchItems {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "at most " + strconv.Itoa(maxBatchItems) + " readings per batch"})
+	}
+
+	dev := CurrentDevice(c)
+	hint := schemaHint(c)
+	rds := make([]*models.Reading, len(items))
+	results := make([]service.SyncResult, len(items))
+	for i, raw := range items {
+		results[i].Index = i
+		rd, err := batchReading(raw, hint, dev.SerialNumber)
+		if err != nil {
+			results[i].ClientReadingID = rd.ClientReadingID
+			results[i].Status, results[i].Reason = service.SyncRejected, err.Error()
+			continue
+		}
+		rds[i] = rd
+	}
+
+	err = h.rs.SyncReadings(context.Background(), rds, results, dev.SerialNumber)
+	if errors.Is(err, service.ErrCalibrationExpired) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	summary := map[string]int{service.SyncCreated: 0, service.SyncDuplicate: 0, service.SyncRejected: 0}
+	for _, r := range results {
+		summary[r.Status]++
+	}
+	return c.JSON(fiber.Map{"summary": summary, "results": results})
+}
+
+// batchReading decodes one batch item. The returned reading is never nil so
+// the caller can echo its client_reading_id when rejecting it.
+func batchReading(raw json.RawMessage, hint int, serial string) (*models.Reading, error) {
+	p, err := decodeSyncPayload(raw, hint)
+	if err != nil {
+		return &models.Reading{}, err
+	}
+	rd, err := p.reading()
+	if err != nil {
+		return rd, err
+	}
+	if p.DeviceSerial != "" && p.DeviceSerial != serial {
+		return rd, errors.New("device_serial does not match signing device")
+	}
+	return rd, nil
+}
+
+func ndjsonBody(c *fiber.Ctx) bool {
+	return strings.HasPrefix(c.Get(fiber.HeaderContentType), mediaTypeNDJSON)
+}
+
+// splitBatch returns the raw items of a JSON array or NDJSON body.
+func splitBatch(c *fiber.Ctx) ([]json.RawMessage, error) {
+	body := c.Body()
+	if ndjsonBody(c) {
+		var items []json.RawMessage
+		sc := bufio.NewScanner(bytes.NewReader(body))
+		sc.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+		for sc.Scan() {
+			line := bytes.TrimSpace(sc.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			items = append(items, json.RawMessage(bytes.Clone(line)))
+		}
+		return items, sc.Err()
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, errors.New("body must be a JSON array or NDJSON")
+	}
+	return items, nil
+}
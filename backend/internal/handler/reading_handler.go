@@ -3,11 +3,13 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
 	"edora/backend/internal/service"
 )
 
@@ -20,59 +22,133 @@ func NewReadingHandler(rs *service.ReadingService) *ReadingHandler {
 }
 
 type syncPayload struct {
-	DeviceSerial   string          `json:"device_serial"`
-	PatientID      string          `json:"patient_id"`
-	DoctorID       string          `json:"doctor_id"`
-	BMDResult      float64         `json:"bmd_result"`
-	TScore         float64         `json:"t_score"`
-	Classification string          `json:"classification"`
-	RawSignalData  json.RawMessage `json:"raw_signal_data"`
-	Lat            float64         `json:"lat"`
-	Long           float64         `json:"long"`
-	Timestamp      string          `json:"timestamp"`
+	ClientReadingID string          `json:"client_reading_id"`
+	DeviceSerial    string          `json:"device_serial"`
+	PatientID       string          `json:"patient_id"`
+	DoctorID        string          `json:"doctor_id"`
+	BMDResult       *float64        `json:"bmd_result"`
+	TScore          *float64        `json:"t_score"`
+	Classification  string          `json:"classification"`
+	RawSignalData   json.RawMessage `json:"raw_signal_data"`
+	Lat             float64         `json:"lat"`
+	Long            float64         `json:"long"`
+	Timestamp       string          `json:"timestamp"`
 }
 
+// reading maps the payload to a reading. A missing timestamp means now; an
+// invalid one is an error.
+func (p *syncPayload) reading() (*models.Reading, error) {
+	rd := &models.Reading{
+		ClientReadingID: p.ClientReadingID,
+		PatientID:       p.PatientID,
+		DoctorID:        p.DoctorID,
+		ClientBMDResult: p.BMDResult,
+		ClientTScore:    p.TScore,
+		Classification:  p.Classification,
+		RawSignalData:   p.RawSignalData,
+		Latitude:        p.Lat,
+		Longitude:       p.Long,
+	}
+	// client values stand unless the server can analyse the signal
+	if p.BMDResult != nil {
+		rd.BMDResult = *p.BMDResult
+	}
+	if p.TScore != nil {
+		rd.TScore = *p.TScore
+	}
+	if p.Timestamp == "" {
+		rd.CreatedAt = time.Now().UTC()
+		return rd, nil
+	}
+	t, err := time.Parse(time.RFC3339, p.Timestamp)
+	if err != nil {
+		return rd, errors.New("timestamp must be RFC 3339")
+	}
+	rd.CreatedAt = t
+	return rd, nil
+}
+
+// SyncReading stores a reading from a signed device, in the flat v1 or the
+// nested v2 schema (see decodeSyncPayload). The app should send a
+// client_reading_id (or Idempotency-Key header); a retry with the same ID
+// returns the original reading with 200 instead of creating a duplicate.
+// Bodies other than JSON, e.g. forms, get 415.
 func (h *ReadingHandler) SyncReading(c *fiber.Ctx) error {
-	var p syncPayload
-	if err := c.BodyParser(&p); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	if !jsonBody(c) {
+		return unsupportedMediaType(c, fiber.MIMEApplicationJSON, mediaTypeV2)
+	}
+	p, err := decodeSyncPayload(c.Body(), schemaHint(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	rd := &models.Reading{
-		PatientID:      p.PatientID,
-		DoctorID:       p.DoctorID,
-		BMDResult:      p.BMDResult,
-		TScore:         p.TScore,
-		Classification: p.Classification,
-		RawSignalData:  p.RawSignalData,
-		Latitude:       p.Lat,
-		Longitude:      p.Long,
-	}
-	if p.Timestamp != "" {
-		if t, err := time.Parse(time.RFC3339, p.Timestamp); err == nil {
-			rd.CreatedAt = t
-		} else {
-			rd.CreatedAt = time.Now().UTC()
+	if key := c.Get("Idempotency-Key"); key != "" {
+		if p.ClientReadingID != "" && p.ClientReadingID != key {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "client_reading_id does not match Idempotency-Key"})
 		}
-	} else {
-		rd.CreatedAt = time.Now().UTC()
+		p.ClientReadingID = key
+	}
+
+	// the signed X-Device-Serial wins; a different serial in the body is rejected
+	dev := CurrentDevice(c)
+	if p.DeviceSerial == "" {
+		p.DeviceSerial = dev.SerialNumber
+	}
+	if p.DeviceSerial != dev.SerialNumber {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "device_serial does not match signing device"})
 	}
 
-	id, err := h.rs.SyncReading(context.Background(), rd, p.DeviceSerial)
+	// unparseable timestamp: rejected as in the batch, the app keeps the scan queued
+	rd, err := p.reading()
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"id": id})
+
+	id, created, err := h.rs.SyncReading(context.Background(), rd, p.DeviceSerial)
+	var sigErr *service.SignalError
+	if errors.As(err, &sigErr) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error(), "problems": sigErr.Problems})
+	}
+	if errors.Is(err, service.ErrCalibrationExpired) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !created {
+		return c.JSON(fiber.Map{"id": id, "duplicate": true})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"id":                  id,
+		"calibration_overdue": rd.CalibrationOverdue,
+		"algorithm_version":   rd.AlgorithmVersion,
+		"bmd_result":          rd.BMDResult,
+		"t_score":             rd.TScore,
+		"z_score":             rd.ZScore,
+		"reference_version":   rd.ReferenceVersion,
+		"classification":      rd.Classification,
+		"diagnosis_rationale": rd.DiagnosisRationale,
+		"rule_set_version":    rd.RuleSetVersion,
+		"bmd_discrepancy":     rd.BMDDiscrepancy,
+		"t_score_discrepancy": rd.TScoreDiscrepancy,
+		"discrepancy_flagged": rd.DiscrepancyFlagged,
+	})
 }
 
-// --- FUNGSI LOGIKA DIAGNOSIS (Sesuai Standar WHO) ---
-func determineDiagnosis(score float64) string {
-	if score >= -1.0 {
-		return "Normal"
-	} else if score > -2.5 {
-		return "Osteopenia"
+// Discrepancies lists recent readings whose client-computed values differ
+// from the server analysis. Query params: days (default 30), limit (default
+// 100, max 1000).
+func (h *ReadingHandler) Discrepancies(c *fiber.Ctx) error {
+	days := c.QueryInt("days", 30)
+	limit := c.QueryInt("limit", 100)
+	if days < 1 || limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "days must be positive and limit between 1 and 1000"})
 	}
-	return "Osteoporosis"
+	rds, err := h.rs.Discrepancies(context.Background(), time.Duration(days)*24*time.Hour, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(rds)
 }
 
 // CreateMedicalRecord handler untuk menyimpan hasil scan/medical record
@@ -90,16 +166,23 @@ func (h *ReadingHandler) CreateMedicalRecord(c *fiber.Ctx) error {
 		}
 	}
 
-	// 1. Hitung diagnosis otomatis
-	input.Diagnosis = determineDiagnosis(input.TScore)
 	if input.ScanDate.IsZero() {
 		input.ScanDate = time.Now().UTC()
 	}
+	input.DoctorID = CurrentUser(c).ID
 
-	// 2. Simpan via service
+	// Skor T/Z, diagnosis, dan rasionalnya dihitung di service
 	mr, err := h.rs.CreateMedicalRecord(context.Background(), &input)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrReferenceScore),
+			errors.Is(err, service.ErrNoDiagnosis):
+			status = fiber.StatusUnprocessableEntity
+		case errors.Is(err, repository.ErrNotFound):
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusCreated).JSON(mr)
 }
@@ -120,3 +203,97 @@ func (h *ReadingHandler) GetPatientRecords(c *fiber.Ctx) error {
 	}
 	return c.Status(fiber.StatusOK).JSON(records)
 }
+
+// PatientTrend returns the BMD change between a patient's scans and whether
+// each exceeds the least significant change of the scanners. Query param:
+// site (all sites when empty).
+func (h *ReadingHandler) PatientTrend(c *fiber.Ctx) error {
+	trend, err := h.rs.Trend(context.Background(), c.Params("id"), c.Query("site"))
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "patient not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(trend)
+}
+
+type reviewRequest struct {
+	Notes string `json:"notes"`
+}
+
+// Review, Sign and Reject move a scan result through the review workflow;
+// see service.ReviewRecord. The body may carry notes (required to reject).
+func (h *ReadingHandler) Review(c *fiber.Ctx) error {
+	return h.reviewAction(c, service.ReviewActionReview)
+}
+
+func (h *ReadingHandler) Sign(c *fiber.Ctx) error {
+	return h.reviewAction(c, service.ReviewActionSign)
+}
+
+func (h *ReadingHandler) Reject(c *fiber.Ctx) error {
+	return h.reviewAction(c, service.ReviewActionReject)
+}
+
+func (h *ReadingHandler) reviewAction(c *fiber.Ctx, action string) error {
+	var req reviewRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+		}
+	}
+	mr, err := h.rs.ReviewRecord(context.Background(), CurrentUser(c), c.Params("id"), action, req.Notes)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.JSON(mr)
+}
+
+// Worklist returns the unsigned scan results assigned to the requesting
+// doctor, oldest first. Query param: limit (default 100, max 1000).
+func (h *ReadingHandler) Worklist(c *fiber.Ctx) error {
+	return h.worklist(c, func(limit int) ([]models.MedicalRecord, error) {
+		return h.rs.Worklist(context.Background(), CurrentUser(c).ID, limit)
+	})
+}
+
+// UnassignedQueue returns the unsigned scan results assigned to no doctor,
+// oldest first, for any doctor to pick up. Query param: limit (default 100,
+// max 1000).
+func (h *ReadingHandler) UnassignedQueue(c *fiber.Ctx) error {
+	return h.worklist(c, func(limit int) ([]models.MedicalRecord, error) {
+		return h.rs.UnassignedQueue(context.Background(), limit)
+	})
+}
+
+func (h *ReadingHandler) worklist(c *fiber.Ctx, list func(limit int) ([]models.MedicalRecord, error)) error {
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "limit must be between 1 and 1000"})
+	}
+	records, err := list(limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if records == nil {
+		records = []models.MedicalRecord{}
+	}
+	return c.JSON(records)
+}
+
+func reviewError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrReasonRequired):
+		status = fiber.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrNotAssigned):
+		status = fiber.StatusForbidden
+	case errors.Is(err, service.ErrRecordSigned),
+		errors.Is(err, service.ErrInvalidTransition):
+		status = fiber.StatusConflict
+	case errors.Is(err, repository.ErrNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Reading payload schema versions. v1 is the original flat syncPayload; v2
+// is the nested shape produced by the mobile app's Reading.toJson.
+const (
+	schemaV1 = 1
+	schemaV2 = 2
+)
+
+// mediaTypeV2 selects v2 for every item when sent as Content-Type or Accept.
+const mediaTypeV2 = "application/vnd.edora.reading.v2+json"
+
+type syncPayloadV2 struct {
+	ClientReadingID string `json:"client_reading_id"`
+	DeviceSerial    string `json:"device_serial"`
+	PatientID       string `json:"patient_id"`
+	DoctorID        string `json:"doctor_id"`
+	Timestamp       string `json:"timestamp"`
+	Location        *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"location"`
+	Analysis *struct {
+		BMD    *float64 `json:"bmd"`
+		TScore *float64 `json:"t_score"`
+		Class  string   `json:"class"`
+	} `json:"analysis"`
+	RawSignalData json.RawMessage `json:"raw_signal_data"`
+	// older app builds send the points under "readings" as well
+	Readings json.RawMessage `json:"readings"`
+}
+
+func (p *syncPayloadV2) flat() syncPayload {
+	out := syncPayload{
+		ClientReadingID: p.ClientReadingID,
+		DeviceSerial:    p.DeviceSerial,
+		PatientID:       p.PatientID,
+		DoctorID:        p.DoctorID,
+		Timestamp:       p.Timestamp,
+		RawSignalData:   p.RawSignalData,
+	}
+	if len(out.RawSignalData) == 0 || string(out.RawSignalData) == "null" {
+		out.RawSignalData = p.Readings
+	}
+	if p.Location != nil {
+		out.Lat, out.Long = p.Location.Lat, p.Location.Lng
+	}
+	if p.Analysis != nil {
+		out.BMDResult, out.TScore, out.Classification = p.Analysis.BMD, p.Analysis.TScore, p.Analysis.Class
+	}
+	return out
+}
+
+// mediaTypeNDJSON is the one non-JSON body the batch endpoint accepts.
+const mediaTypeNDJSON = "application/x-ndjson"
+
+// jsonBody reports whether the request's Content-Type is JSON:
+// application/json, or application/*+json such as mediaTypeV2. A missing
+// Content-Type is taken as JSON, as older app builds sent none.
+func jsonBody(c *fiber.Ctx) bool {
+	ct := c.Get(fiber.HeaderContentType)
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == fiber.MIMEApplicationJSON || (strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json"))
+}
+
+// unsupportedMediaType rejects a sync body that is neither v1 nor v2 JSON;
+// form bodies are not accepted.
+func unsupportedMediaType(c *fiber.Ctx, accepted ...string) error {
+	return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+		"error": "Content-Type must be one of " + strings.Join(accepted, ", "),
+	})
+}
+
+// schemaHint returns the version requested through the media type, or 0.
+func schemaHint(c *fiber.Ctx) int {
+	if strings.Contains(c.Get(fiber.HeaderContentType), mediaTypeV2) || strings.Contains(c.Get(fiber.HeaderAccept), mediaTypeV2) {
+		return schemaV2
+	}
+	return 0
+}
+
+// decodeSyncPayload decodes one reading in either schema. The body's
+// schema_version wins over hint; without either, a payload with a nested
+// location or analysis object is taken as v2 and anything else as v1.
+func decodeSyncPayload(raw []byte, hint int) (syncPayload, error) {
+	var probe struct {
+		SchemaVersion int             `json:"schema_version"`
+		Location      json.RawMessage `json:"location"`
+		Analysis      json.RawMessage `json:"analysis"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return syncPayload{}, errors.New("invalid reading: " + err.Error())
+	}
+
+	version := probe.SchemaVersion
+	if version == 0 {
+		version = hint
+	}
+	if version == 0 {
+		version = schemaV1
+		if len(probe.Location) > 0 || len(probe.Analysis) > 0 {
+			version = schemaV2
+		}
+	}
+
+	switch version {
+	case schemaV1:
+		var p syncPayload
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return p, errors.New("invalid v1 reading: " + err.Error())
+		}
+		return p, nil
+	case schemaV2:
+		var p syncPayloadV2
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return syncPayload{}, errors.New("invalid v2 reading: " + err.Error())
+		}
+		return p.flat(), nil
+	}
+	return syncPayload{}, errors.New("unsupported schema_version " + strconv.Itoa(version))
+}
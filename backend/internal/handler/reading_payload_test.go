@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDecodeSyncPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		hint     int
+		want     syncPayload
+		wantBMD  float64
+		wantSig  string
+		wantFail bool
+	}{
+		{
+			name:    "v1 flat",
+			body:    `{"client_reading_id":"r1","patient_id":"p1","bmd_result":0.91,"classification":"Normal","lat":-6.2,"long":106.8,"raw_signal_data":[1,2]}`,
+			want:    syncPayload{ClientReadingID: "r1", PatientID: "p1", Classification: "Normal", Lat: -6.2, Long: 106.8},
+			wantBMD: 0.91,
+			wantSig: `[1,2]`,
+		},
+		{
+			name:    "v2 detected from nesting",
+			body:    `{"client_reading_id":"r2","location":{"lat":-6.2,"lng":106.8},"analysis":{"bmd":0.8,"class":"Osteopenia"},"raw_signal_data":[3]}`,
+			want:    syncPayload{ClientReadingID: "r2", Classification: "Osteopenia", Lat: -6.2, Long: 106.8},
+			wantBMD: 0.8,
+			wantSig: `[3]`,
+		},
+		{
+			name:    "v2 points under readings",
+			body:    `{"client_reading_id":"r3","analysis":{"bmd":0.7},"readings":[4,5]}`,
+			want:    syncPayload{ClientReadingID: "r3"},
+			wantBMD: 0.7,
+			wantSig: `[4,5]`,
+		},
+		{
+			name:    "hint selects v2 without nesting",
+			body:    `{"client_reading_id":"r4","bmd_result":0.9}`,
+			hint:    schemaV2,
+			want:    syncPayload{ClientReadingID: "r4"},
+			wantSig: ``,
+		},
+		{
+			name:    "schema_version wins over the hint",
+			body:    `{"schema_version":1,"client_reading_id":"r5","bmd_result":0.9}`,
+			hint:    schemaV2,
+			want:    syncPayload{ClientReadingID: "r5"},
+			wantBMD: 0.9,
+		},
+		{name: "unsupported schema_version", body: `{"schema_version":3}`, wantFail: true},
+		{name: "not JSON", body: `client_reading_id=r6`, wantFail: true},
+		{name: "v2 with a flat analysis", body: `{"schema_version":2,"analysis":0.9}`, wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSyncPayload([]byte(tt.body), tt.hint)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("decodeSyncPayload error = %v, wantFail %v", err, tt.wantFail)
+			}
+			if err != nil {
+				return
+			}
+			var bmd float64
+			if got.BMDResult != nil {
+				bmd = *got.BMDResult
+			}
+			if bmd != tt.wantBMD || string(got.RawSignalData) != tt.wantSig {
+				t.Errorf("bmd %v signal %s, want %v %s", bmd, got.RawSignalData, tt.wantBMD, tt.wantSig)
+			}
+			got.BMDResult, got.RawSignalData = nil, nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeSyncPayload = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaHint(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+		want        int
+	}{
+		{name: "plain JSON", contentType: fiber.MIMEApplicationJSON},
+		{name: "v2 content type", contentType: mediaTypeV2, want: schemaV2},
+		{name: "v2 accept", contentType: fiber.MIMEApplicationJSON, accept: mediaTypeV2, want: schemaV2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/", func(c *fiber.Ctx) error { return c.SendString(strconv.Itoa(schemaHint(c))) })
+			req := httptest.NewRequest(fiber.MethodPost, "/", nil)
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			if tt.accept != "" {
+				req.Header.Set(fiber.HeaderAccept, tt.accept)
+			}
+			if _, got := respond(t, app, req); got != strconv.Itoa(tt.want) {
+				t.Errorf("schemaHint = %s, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// respond runs req through app and returns the response status and body.
+func respond(t *testing.T, app *fiber.App, req *http.Request) (int, string) {
+	t.Helper()
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestJSONBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{contentType: "application/json; charset=utf-8", want: true},
+		{contentType: mediaTypeV2, want: true},
+		{contentType: "", want: true},
+		{contentType: fiber.MIMEApplicationForm},
+		{contentType: fiber.MIMEMultipartForm + "; boundary=x"},
+		{contentType: fiber.MIMETextPlain},
+		{contentType: mediaTypeNDJSON},
+		{contentType: "application/json;;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/", func(c *fiber.Ctx) error { return c.SendString(strconv.FormatBool(jsonBody(c))) })
+			req := httptest.NewRequest(fiber.MethodPost, "/", nil)
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			if _, got := respond(t, app, req); got != strconv.FormatBool(tt.want) {
+				t.Errorf("jsonBody = %s, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncRejectsOtherMediaTypes(t *testing.T) {
+	// the media type is checked before the service is used
+	h := &ReadingHandler{}
+	tests := []struct {
+		name        string
+		handler     fiber.Handler
+		contentType string
+	}{
+		{name: "form single", handler: h.SyncReading, contentType: fiber.MIMEApplicationForm},
+		{name: "NDJSON single", handler: h.SyncReading, contentType: mediaTypeNDJSON},
+		{name: "form batch", handler: h.SyncReadingsBatch, contentType: fiber.MIMEApplicationForm},
+		{name: "text batch", handler: h.SyncReadingsBatch, contentType: fiber.MIMETextPlain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/", tt.handler)
+			req := httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader("client_reading_id=r1"))
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			if status, body := respond(t, app, req); status != fiber.StatusUnsupportedMediaType {
+				t.Errorf("status = %d (%s), want 415", status, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+	"edora/backend/internal/service"
+)
+
+// UserHandler exposes admin-only user management endpoints.
+type UserHandler struct {
+	svc *service.UserService
+}
+
+func NewUserHandler(s *service.UserService) *UserHandler {
+	return &UserHandler{svc: s}
+}
+
+type createUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+func (h *UserHandler) Create(c *fiber.Ctx) error {
+	var req createUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+
+	u, err := h.svc.CreateUser(context.Background(), req.Username, req.Password, req.Role)
+	if err != nil {
+		return userError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(u)
+}
+
+func (h *UserHandler) List(c *fiber.Ctx) error {
+	users, err := h.svc.ListUsers(context.Background())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+	return c.JSON(users)
+}
+
+func (h *UserHandler) Get(c *fiber.Ctx) error {
+	u, err := h.svc.GetUser(context.Background(), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if u == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+	}
+	return c.JSON(u)
+}
+
+func (h *UserHandler) UpdateRole(c *fiber.Ctx) error {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+	if err := h.svc.UpdateRole(context.Background(), c.Params("id"), req.Role); err != nil {
+		return userError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *UserHandler) Disable(c *fiber.Ctx) error {
+	if err := h.svc.SetDisabled(context.Background(), CurrentUser(c).ID, c.Params("id"), true); err != nil {
+		return userError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *UserHandler) Enable(c *fiber.Ctx) error {
+	if err := h.svc.SetDisabled(context.Background(), CurrentUser(c).ID, c.Params("id"), false); err != nil {
+		return userError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *UserHandler) Delete(c *fiber.Ctx) error {
+	if err := h.svc.DeleteUser(context.Background(), CurrentUser(c).ID, c.Params("id")); err != nil {
+		return userError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// userError maps user service errors to HTTP status codes.
+func userError(c *fiber.Ctx, err error) error {
+	var policy *service.PasswordPolicyError
+	if errors.As(err, &policy) {
+		return passwordPolicyError(c, policy)
+	}
+
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidRole),
+		errors.Is(err, service.ErrInvalidUsername),
+		errors.Is(err, service.ErrSelfModify):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, repository.ErrUsernameTaken):
+		status = fiber.StatusConflict
+	case errors.Is(err, repository.ErrNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
+
+func passwordPolicyError(c *fiber.Ctx, err *service.PasswordPolicyError) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "password does not meet policy", "problems": err.Problems})
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Calibration enforcement modes for readings from devices whose calibration
+// has expired.
+const (
+	CalibrationEnforceOff    = "off"
+	CalibrationEnforceFlag   = "flag"
+	CalibrationEnforceReject = "reject"
+)
+
+type DeviceCalibration struct {
+	ID               string    `json:"id" db:"id"`
+	DeviceID         string    `json:"device_id" db:"device_id"`
+	PerformedAt      time.Time `json:"performed_at" db:"performed_at"`
+	Technician       string    `json:"technician" db:"technician"`
+	PhantomReference []float64 `json:"phantom_reference" db:"phantom_reference"`
+	MeasuredValues   []float64 `json:"measured_values" db:"measured_values"`
+	Passed           bool      `json:"passed" db:"passed"`
+	Notes            string    `json:"notes" db:"notes"`
+	CreatedBy        string    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// CalibrationPolicy is how often devices of a facility must be calibrated
+// and what happens to readings once that is overdue.
+type CalibrationPolicy struct {
+	Facility     string `json:"facility" db:"facility"`
+	IntervalDays int    `json:"interval_days" db:"interval_days"`
+	Enforcement  string `json:"enforcement" db:"enforcement"`
+}
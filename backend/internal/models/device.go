@@ -2,11 +2,78 @@ package models
 
 import "time"
 
+// Device status values.
+const (
+	DeviceOnline         = "online"
+	DeviceOffline        = "offline"
+	DeviceMaintenance    = "maintenance"
+	DeviceDecommissioned = "decommissioned"
+)
+
 type Device struct {
-	ID           string    `json:"id" db:"id"`
-	SerialNumber string    `json:"serial_number" db:"serial_number"`
-	Name         string    `json:"name" db:"name"`
-	Status       string    `json:"status" db:"status"`
-	LastSeen     time.Time `json:"last_seen" db:"last_seen"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ID               string     `json:"id" db:"id"`
+	SerialNumber     string     `json:"serial_number" db:"serial_number"`
+	Name             string     `json:"name" db:"name"`
+	Facility         string     `json:"facility" db:"facility"`
+	Status           string     `json:"status" db:"status"`
+	FirmwareVersion  string     `json:"firmware_version" db:"firmware_version"`
+	HardwareRevision int        `json:"hardware_revision,omitempty" db:"hardware_revision"`
+	LastSeen         time.Time  `json:"last_seen" db:"last_seen"`
+	DecommissionedAt *time.Time `json:"decommissioned_at,omitempty" db:"decommissioned_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+
+	// LastCalibratedAt is the last passing phantom calibration;
+	// CalibrationDueAt is computed from it and the facility policy.
+	LastCalibratedAt *time.Time `json:"last_calibrated_at,omitempty" db:"last_calibrated_at"`
+	CalibrationDueAt *time.Time `json:"calibration_due_at,omitempty" db:"-"`
+	// CalibrationBaselineAt is set on devices registered before calibration
+	// was tracked; uncalibrated, they get one policy interval from it.
+	CalibrationBaselineAt *time.Time `json:"-" db:"calibration_baseline_at"`
+
+	// LSC is the least significant BMD change (g/cm²) of the scanner,
+	// 2.77 times its precision error; nil uses the server default.
+	LSC *float64 `json:"lsc_bmd,omitempty" db:"lsc_bmd"`
+
+	// Secret is the HMAC key the device signs requests with; never serialized.
+	Secret string `json:"-" db:"api_secret"`
+}
+
+// Accepting reports whether the device may upload readings.
+func (d *Device) Accepting() bool {
+	return d.Status != DeviceMaintenance && d.Status != DeviceDecommissioned
+}
+
+// Presence states computed from last_seen.
+const (
+	PresenceOnline  = "online"
+	PresenceStale   = "stale"
+	PresenceOffline = "offline"
+)
+
+// DeviceSummary is one row of the device list.
+type DeviceSummary struct {
+	Device
+	ReadingsToday int    `json:"readings_today"`
+	Presence      string `json:"presence"`
+}
+
+// DeviceStatusChange is one row of device_status_history.
+type DeviceStatusChange struct {
+	FromStatus string    `json:"from_status,omitempty" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// DeviceTelemetry is one heartbeat sample. Optional metrics are nil when the
+// device did not report them.
+type DeviceTelemetry struct {
+	RecordedAt       time.Time `json:"recorded_at" db:"recorded_at"`
+	FirmwareVersion  string    `json:"firmware_version,omitempty" db:"firmware_version"`
+	BatteryLevel     *float64  `json:"battery_level,omitempty" db:"battery_level"`
+	Temperature      *float64  `json:"temperature,omitempty" db:"temperature"`
+	FreeStorageBytes *int64    `json:"free_storage_bytes,omitempty" db:"free_storage_bytes"`
+
+	// HardwareRevision is reported with the heartbeat but kept on the device.
+	HardwareRevision *int `json:"-" db:"-"`
 }
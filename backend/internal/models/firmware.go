@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+type FirmwareRelease struct {
+	ID             string `json:"id" db:"id"`
+	Version        string `json:"version" db:"version"`
+	ChecksumSHA256 string `json:"checksum_sha256" db:"checksum_sha256"`
+	SizeBytes      int64  `json:"size_bytes" db:"size_bytes"`
+	FileName       string `json:"-" db:"file_name"`
+	ReleaseNotes   string `json:"release_notes" db:"release_notes"`
+	// Hardware revision bounds are inclusive; nil means unbounded.
+	MinHardwareRevision *int      `json:"min_hardware_revision,omitempty" db:"min_hardware_revision"`
+	MaxHardwareRevision *int      `json:"max_hardware_revision,omitempty" db:"max_hardware_revision"`
+	RolloutPercent      int       `json:"rollout_percent" db:"rollout_percent"`
+	CreatedBy           string    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Supports reports whether the release may be installed on the given
+// hardware revision (0 when the device never reported one).
+func (r *FirmwareRelease) Supports(hwRevision int) bool {
+	if r.MinHardwareRevision != nil && hwRevision < *r.MinHardwareRevision {
+		return false
+	}
+	if r.MaxHardwareRevision != nil && hwRevision > *r.MaxHardwareRevision {
+		return false
+	}
+	return true
+}
+
+// DeviceFirmwareUpdate is the rollout progress of one release on one device.
+type DeviceFirmwareUpdate struct {
+	DeviceID     string     `json:"device_id" db:"device_id"`
+	SerialNumber string     `json:"serial_number" db:"serial_number"`
+	Version      string     `json:"version" db:"version"`
+	FromVersion  string     `json:"from_version,omitempty" db:"from_version"`
+	OfferedAt    time.Time  `json:"offered_at" db:"offered_at"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty" db:"downloaded_at"`
+	InstalledAt  *time.Time `json:"installed_at,omitempty" db:"installed_at"`
+}
+
+// FirmwareManifest is what a device gets when it polls for updates.
+type FirmwareManifest struct {
+	CurrentVersion  string `json:"current_version"`
+	UpdateAvailable bool   `json:"update_available"`
+	Version         string `json:"version,omitempty"`
+	ChecksumSHA256  string `json:"checksum_sha256,omitempty"`
+	SizeBytes       int64  `json:"size_bytes,omitempty"`
+	ReleaseNotes    string `json:"release_notes,omitempty"`
+	DownloadURL     string `json:"download_url,omitempty"`
+}
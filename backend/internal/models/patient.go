@@ -15,11 +15,69 @@ type Patient struct {
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// Sources of a scan result.
+const (
+	SourceDevice = "device" // synced by a scanner
+	SourceManual = "manual" // entered by staff
+)
+
+// Review states of a scan result. Signed and rejected are final. Legacy
+// results were stored before the review workflow existed and stay out of it.
+const (
+	ReviewPending  = "pending_review"
+	ReviewReviewed = "reviewed"
+	ReviewSigned   = "signed"
+	ReviewRejected = "rejected"
+	ReviewLegacy   = "legacy"
+)
+
+// Review is the clinician oversight of a scan result.
+type Review struct {
+	ReviewStatus string     `json:"review_status"`
+	ReviewNotes  string     `json:"review_notes,omitempty"`
+	ReviewedBy   string     `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	SignedBy     string     `json:"signed_by,omitempty"`
+	SignedAt     *time.Time `json:"signed_at,omitempty"`
+}
+
+// MedicalRecord is one scan result of a patient, synced by a scanner or
+// entered manually; both are stored in readings.
+//
+// ID is the readings UUID. Until manual results moved into readings it was
+// the integer medical_records id; results migrated from there keep that
+// number in LegacyID so clients holding old ids can map them.
 type MedicalRecord struct {
-	ID        int       `json:"id"`
-	PatientID string    `json:"patient_id"` 
+	ID        string    `json:"id"`
+	LegacyID  *int      `json:"legacy_id,omitempty"`
+	PatientID string    `json:"patient_id"`
 	TScore    float64   `json:"t_score"`
 	Diagnosis string    `json:"diagnosis"`
 	ScanDate  time.Time `json:"scan_date"`
 	Notes     string    `json:"notes"`
+	Source    string    `json:"source"`
+	DoctorID  string    `json:"doctor_id,omitempty"`
+	// DeviceSerial is the scanner used; its LSC applies to trend analysis.
+	DeviceSerial string `json:"device_serial,omitempty"`
+
+	// BMD, when given, is scored server-side against the reference
+	// population; ReferenceVersion names the dataset used.
+	BMD              *float64 `json:"bmd_result"`
+	Site             string   `json:"site,omitempty"`
+	ZScore           *float64 `json:"z_score"`
+	ReferenceVersion string   `json:"reference_version,omitempty"`
+
+	// DiagnosisRationale explains Diagnosis; RuleSetVersion names the
+	// diagnosis rules that produced it.
+	DiagnosisRationale string `json:"diagnosis_rationale"`
+	RuleSetVersion     string `json:"rule_set_version"`
+
+	// 10-year fracture probability in percent from the patient's risk
+	// factors and TScore; nil without a risk questionnaire.
+	MajorFractureRisk    *float64 `json:"major_fracture_risk"`
+	HipFractureRisk      *float64 `json:"hip_fracture_risk"`
+	TreatmentRecommended *bool    `json:"treatment_recommended"`
+	RiskModelVersion     string   `json:"risk_model_version,omitempty"`
+
+	Review
 }
@@ -11,6 +11,9 @@ type Reading struct {
 	PatientID string `json:"patient_id" db:"patient_id"`
 	DoctorID  string `json:"doctor_id" db:"doctor_id"`
 
+	// ClientReadingID is generated by the app so retried syncs are idempotent.
+	ClientReadingID string `json:"client_reading_id,omitempty" db:"client_reading_id"`
+
 	BMDResult      float64 `json:"bmd_result" db:"bmd_result"`
 	TScore         float64 `json:"t_score" db:"t_score"`
 	Classification string  `json:"classification" db:"classification"`
@@ -20,6 +23,37 @@ type Reading struct {
 	Latitude  float64   `json:"latitude" db:"latitude"`
 	Longitude float64   `json:"longitude" db:"longitude"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// CalibrationOverdue is set when the device was past its calibration
+	// due date when the reading was synced.
+	CalibrationOverdue bool `json:"calibration_overdue" db:"calibration_overdue"`
+
+	// AlgorithmVersion is the analysis model that computed BMDResult and
+	// TScore from the signal; empty when the client values were kept.
+	AlgorithmVersion   string   `json:"algorithm_version,omitempty" db:"algorithm_version"`
+	ClientBMDResult    *float64 `json:"client_bmd_result,omitempty" db:"client_bmd_result"`
+	ClientTScore       *float64 `json:"client_t_score,omitempty" db:"client_t_score"`
+	BMDDiscrepancy     *float64 `json:"bmd_discrepancy,omitempty" db:"bmd_discrepancy"`
+	TScoreDiscrepancy  *float64 `json:"t_score_discrepancy,omitempty" db:"t_score_discrepancy"`
+	DiscrepancyFlagged bool     `json:"discrepancy_flagged" db:"discrepancy_flagged"`
+
+	// Site, ZScore and ReferenceVersion are set when BMDResult was scored
+	// against the reference population, as for manual records.
+	Site             string   `json:"site,omitempty" db:"site"`
+	ZScore           *float64 `json:"z_score,omitempty" db:"z_score"`
+	ReferenceVersion string   `json:"reference_version,omitempty" db:"reference_version"`
+
+	// DiagnosisRationale explains Classification; RuleSetVersion names the
+	// diagnosis rules that produced it.
+	DiagnosisRationale string `json:"diagnosis_rationale,omitempty" db:"diagnosis_rationale"`
+	RuleSetVersion     string `json:"rule_set_version,omitempty" db:"rule_set_version"`
+
+	// 10-year fracture probability as for manual records; nil without a
+	// risk questionnaire.
+	MajorFractureRisk    *float64 `json:"major_fracture_risk,omitempty" db:"major_fracture_risk"`
+	HipFractureRisk      *float64 `json:"hip_fracture_risk,omitempty" db:"hip_fracture_risk"`
+	TreatmentRecommended *bool    `json:"treatment_recommended,omitempty" db:"treatment_recommended"`
+	RiskModelVersion     string   `json:"risk_model_version,omitempty" db:"risk_model_version"`
 }
 
 // Request Payload untuk Sync dari Mobile App
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RiskFactors is the clinical fracture risk questionnaire of a patient.
+// Alcohol means three or more units a day; Glucocorticoids means oral use
+// for three months or more.
+type RiskFactors struct {
+	PatientID           string    `json:"patient_id"`
+	PriorFracture       bool      `json:"prior_fracture"`
+	ParentalHipFracture bool      `json:"parental_hip_fracture"`
+	CurrentSmoking      bool      `json:"current_smoking"`
+	Glucocorticoids     bool      `json:"glucocorticoids"`
+	RheumatoidArthritis bool      `json:"rheumatoid_arthritis"`
+	Alcohol             bool      `json:"alcohol"`
+	BMI                 *float64  `json:"bmi"`
+	UpdatedBy           string    `json:"updated_by,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
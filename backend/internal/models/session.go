@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Session is one login on one client. Every refresh token issued from the
+// same login belongs to the same session (token family).
+type Session struct {
+	ID            string     `json:"id" db:"id"`
+	UserID        string     `json:"user_id" db:"user_id"`
+	UserAgent     string     `json:"user_agent" db:"user_agent"`
+	IP            string     `json:"ip" db:"ip"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt    time.Time  `json:"last_used_at" db:"last_used_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason,omitempty" db:"revoked_reason"`
+}
+
+type RefreshToken struct {
+	SessionID string     `db:"session_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// SignalPoint is one sample of the impedance sweep a scanner records:
+// frequency in Hz, impedance magnitude in ohm and phase in degrees.
+type SignalPoint struct {
+	Freq      float64  `json:"freq"`
+	Impedance float64  `json:"impedance"`
+	Phase     *float64 `json:"phase,omitempty"`
+}
+
+// Signal is a sweep ordered by strictly increasing frequency.
+type Signal []SignalPoint
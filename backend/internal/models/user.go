@@ -1,11 +1,36 @@
 package models
 
-import "time"
+import (
+	"slices"
+	"time"
+)
+
+// Roles recognised by the authorization middleware.
+const (
+	RoleAdmin    = "admin"
+	RoleDoctor   = "doctor"
+	RoleOperator = "operator"
+	RoleDevice   = "device"
+)
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	return slices.Contains([]string{RoleAdmin, RoleDoctor, RoleOperator, RoleDevice}, role)
+}
 
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username" db:"username"`
-	Password  string    `json:"password" db:"password"` // stored as bcrypt hash
-	Role      string    `json:"role" db:"role"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID       string `json:"id"`
+	Username string `json:"username" db:"username"`
+	Password string `json:"-" db:"password"` // stored as bcrypt hash, never serialized
+	Role     string `json:"role" db:"role"`
+	Disabled bool   `json:"disabled" db:"disabled"`
+	// MustChangePassword blocks every endpoint except password change until cleared.
+	MustChangePassword bool      `json:"must_change_password" db:"must_change_password"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+
+	// TOTP two-factor authentication
+	TOTPEnabled  bool   `json:"totp_enabled" db:"totp_enabled"`
+	TOTPSecret   string `json:"-" db:"totp_secret"`
+	TOTPLastStep int64  `json:"-" db:"totp_last_step"`
 }
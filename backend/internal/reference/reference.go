@@ -0,0 +1,201 @@
+// Package reference holds the reference-population BMD norms used to turn a
+// measured BMD into a T-score (against young adults of the same sex) and a
+// Z-score (against people of the same sex and age).
+//
+// A dataset is a directory with three files:
+//
+//	VERSION          one line naming the dataset, recorded on every score
+//	young_adult.csv  sex,site,mean,sd
+//	age_norms.csv    sex,site,age_from,age_to,mean,sd (ages inclusive)
+//
+// Sex is M or F; BMD values are g/cm².
+package reference
+
+import (
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Skeletal sites.
+const (
+	SiteFemoralNeck = "femoral_neck"
+	SiteTotalHip    = "total_hip"
+	SiteLumbarSpine = "lumbar_spine"
+)
+
+var (
+	ErrUnknownSex = errors.New("patient sex is not M or F")
+	ErrNoNorm     = errors.New("no reference norm")
+)
+
+//go:embed data
+var builtin embed.FS
+
+// Norm is the mean and standard deviation of BMD in a population.
+type Norm struct {
+	Mean float64
+	SD   float64
+}
+
+type ageNorm struct {
+	from, to int
+	Norm
+}
+
+type key struct{ sex, site string }
+
+// Dataset is one versioned set of reference norms.
+type Dataset struct {
+	Version    string
+	youngAdult map[key]Norm
+	byAge      map[key][]ageNorm
+}
+
+// Default returns the dataset built into the binary.
+func Default() (*Dataset, error) {
+	sub, err := fs.Sub(builtin, "data")
+	if err != nil {
+		return nil, err
+	}
+	return load(sub)
+}
+
+// Load reads a dataset from a directory.
+func Load(dir string) (*Dataset, error) {
+	return load(os.DirFS(dir))
+}
+
+func load(fsys fs.FS) (*Dataset, error) {
+	v, err := fs.ReadFile(fsys, "VERSION")
+	if err != nil {
+		return nil, err
+	}
+	d := &Dataset{Version: strings.TrimSpace(string(v)), youngAdult: map[key]Norm{}, byAge: map[key][]ageNorm{}}
+	if d.Version == "" {
+		return nil, errors.New("reference: empty VERSION")
+	}
+
+	err = readCSV(fsys, "young_adult.csv", 4, func(rec []string) error {
+		n, err := parseNorm(rec[2], rec[3])
+		if err != nil {
+			return err
+		}
+		d.youngAdult[key{rec[0], rec[1]}] = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readCSV(fsys, "age_norms.csv", 6, func(rec []string) error {
+		from, err1 := strconv.Atoi(rec[2])
+		to, err2 := strconv.Atoi(rec[3])
+		if err1 != nil || err2 != nil || from > to {
+			return fmt.Errorf("invalid age band %s-%s", rec[2], rec[3])
+		}
+		n, err := parseNorm(rec[4], rec[5])
+		if err != nil {
+			return err
+		}
+		k := key{rec[0], rec[1]}
+		d.byAge[k] = append(d.byAge[k], ageNorm{from, to, n})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readCSV calls fn for every data row of a CSV file with a header line.
+func readCSV(fsys fs.FS, name string, fields int, fn func([]string) error) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = fields
+	r.TrimLeadingSpace = true
+	if _, err := r.Read(); err != nil {
+		return fmt.Errorf("reference %s: %w", name, err)
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reference %s: %w", name, err)
+		}
+		if rec[0] != "M" && rec[0] != "F" {
+			return fmt.Errorf("reference %s: sex must be M or F, got %q", name, rec[0])
+		}
+		if err := fn(rec); err != nil {
+			return fmt.Errorf("reference %s: %w", name, err)
+		}
+	}
+}
+
+func parseNorm(mean, sd string) (Norm, error) {
+	m, err1 := strconv.ParseFloat(mean, 64)
+	s, err2 := strconv.ParseFloat(sd, 64)
+	if err1 != nil || err2 != nil || m <= 0 || s <= 0 {
+		return Norm{}, fmt.Errorf("invalid mean/sd %s/%s", mean, sd)
+	}
+	return Norm{Mean: m, SD: s}, nil
+}
+
+// Sex normalizes the gender stored on a patient (M/F, male/female,
+// L/P for laki-laki/perempuan) to M or F.
+func Sex(gender string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(gender)) {
+	case "m", "male", "l", "laki-laki", "pria":
+		return "M", nil
+	case "f", "female", "p", "perempuan", "w", "wanita":
+		return "F", nil
+	}
+	return "", ErrUnknownSex
+}
+
+// TScore compares bmd with young adults of the same sex at the site.
+func (d *Dataset) TScore(sex, site string, bmd float64) (float64, error) {
+	n, ok := d.youngAdult[key{sex, site}]
+	if !ok {
+		return 0, fmt.Errorf("%w: young adult %s %s", ErrNoNorm, sex, site)
+	}
+	return score(bmd, n), nil
+}
+
+// ZScore compares bmd with people of the same sex and age at the site.
+func (d *Dataset) ZScore(sex, site string, age int, bmd float64) (float64, error) {
+	for _, band := range d.byAge[key{sex, site}] {
+		if age >= band.from && age <= band.to {
+			return score(bmd, band.Norm), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s %s age %d", ErrNoNorm, sex, site, age)
+}
+
+func score(bmd float64, n Norm) float64 {
+	return math.Round((bmd-n.Mean)/n.SD*10) / 10
+}
+
+// Age is the age in whole years at t of someone born on birth.
+func Age(birth, t time.Time) int {
+	age := t.Year() - birth.Year()
+	if t.Month() < birth.Month() || (t.Month() == birth.Month() && t.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
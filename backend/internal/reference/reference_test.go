@@ -0,0 +1,94 @@
+package reference
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScores(t *testing.T) {
+	d, err := Default()
+	if err != nil {
+		t.Fatalf("Default: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		sex     string
+		site    string
+		age     int
+		bmd     float64
+		wantT   float64
+		wantZ   float64
+		wantErr error // of ZScore; TScore must succeed
+	}{
+		// young adult F femoral neck 0.858 ± 0.120, age 50-59 0.746 ± 0.112
+		{name: "woman at the osteoporosis threshold", sex: "F", site: SiteFemoralNeck, age: 55, bmd: 0.558, wantT: -2.5, wantZ: -1.7},
+		{name: "young adult mean", sex: "F", site: SiteFemoralNeck, age: 25, bmd: 0.858, wantT: 0, wantZ: 0},
+		// M total hip 1.055 ± 0.144, age 60-69 0.966 ± 0.135
+		{name: "man above the mean", sex: "M", site: SiteTotalHip, age: 60, bmd: 1.100, wantT: 0.3, wantZ: 1.0},
+		// last band is open to 120: 0.580 ± 0.105
+		{name: "oldest band", sex: "F", site: SiteFemoralNeck, age: 120, bmd: 0.558, wantT: -2.5, wantZ: -0.2},
+		{name: "no norm under 20", sex: "F", site: SiteFemoralNeck, age: 19, bmd: 0.6, wantT: -2.2, wantErr: ErrNoNorm},
+		{name: "no norm over 120", sex: "M", site: SiteTotalHip, age: 121, bmd: 1.055, wantT: 0, wantErr: ErrNoNorm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.TScore(tt.sex, tt.site, tt.bmd)
+			if err != nil || got != tt.wantT {
+				t.Errorf("TScore = %v, %v; want %v", got, err, tt.wantT)
+			}
+			z, err := d.ZScore(tt.sex, tt.site, tt.age, tt.bmd)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ZScore error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && z != tt.wantZ {
+				t.Errorf("ZScore = %v, want %v", z, tt.wantZ)
+			}
+		})
+	}
+
+	if _, err := d.TScore("F", "radius", 0.6); !errors.Is(err, ErrNoNorm) {
+		t.Errorf("TScore at an unknown site: %v, want %v", err, ErrNoNorm)
+	}
+}
+
+func TestSex(t *testing.T) {
+	tests := []struct {
+		gender string
+		want   string
+	}{
+		{"M", "M"}, {"male", "M"}, {" Laki-laki ", "M"}, {"L", "M"},
+		{"f", "F"}, {"Female", "F"}, {"perempuan", "F"}, {"P", "F"}, {"wanita", "F"},
+		{"", ""}, {"x", ""},
+	}
+	for _, tt := range tests {
+		got, err := Sex(tt.gender)
+		if got != tt.want || (tt.want == "") != errors.Is(err, ErrUnknownSex) {
+			t.Errorf("Sex(%q) = %q, %v; want %q", tt.gender, got, err, tt.want)
+		}
+	}
+}
+
+func TestAge(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name  string
+		birth time.Time
+		at    time.Time
+		want  int
+	}{
+		{name: "day before birthday", birth: date(1960, 6, 15), at: date(2025, 6, 14), want: 64},
+		{name: "on birthday", birth: date(1960, 6, 15), at: date(2025, 6, 15), want: 65},
+		{name: "earlier month", birth: date(1960, 6, 15), at: date(2025, 5, 30), want: 64},
+		{name: "leap day birth in a common year", birth: date(2000, 2, 29), at: date(2021, 2, 28), want: 20},
+		{name: "leap day birth after February", birth: date(2000, 2, 29), at: date(2021, 3, 1), want: 21},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Age(tt.birth, tt.at); got != tt.want {
+				t.Errorf("Age = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"edora/backend/internal/models"
+)
+
+// CalibrationRepo is the calibration storage used by the calibration service.
+type CalibrationRepo interface {
+	Create(ctx context.Context, cal *models.DeviceCalibration) error
+	List(ctx context.Context, deviceID string) ([]models.DeviceCalibration, error)
+	GetPolicy(ctx context.Context, facility string) (*models.CalibrationPolicy, error)
+	ListPolicies(ctx context.Context) (map[string]models.CalibrationPolicy, error)
+	UpsertPolicy(ctx context.Context, p models.CalibrationPolicy) error
+}
+
+type CalibrationRepository struct {
+	db *sql.DB
+}
+
+func NewCalibrationRepository(db *sql.DB) *CalibrationRepository {
+	return &CalibrationRepository{db: db}
+}
+
+// Create stores a calibration run.
+func (r *CalibrationRepository) Create(ctx context.Context, cal *models.DeviceCalibration) error {
+	ref, err := json.Marshal(cal.PhantomReference)
+	if err != nil {
+		return err
+	}
+	measured, err := json.Marshal(cal.MeasuredValues)
+	if err != nil {
+		return err
+	}
+	q := `INSERT INTO device_calibrations (device_id, performed_at, technician, phantom_reference, measured_values, passed, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, '')::uuid)
+		RETURNING id, created_at`
+	return r.db.QueryRowContext(ctx, q, cal.DeviceID, cal.PerformedAt, cal.Technician, ref, measured, cal.Passed, cal.Notes, cal.CreatedBy).
+		Scan(&cal.ID, &cal.CreatedAt)
+}
+
+// List returns the calibration runs of a device, most recent first.
+func (r *CalibrationRepository) List(ctx context.Context, deviceID string) ([]models.DeviceCalibration, error) {
+	q := `SELECT id, device_id, performed_at, technician, phantom_reference, measured_values, passed, COALESCE(notes, ''),
+			COALESCE(created_by::text, ''), created_at
+		FROM device_calibrations WHERE device_id = $1 ORDER BY performed_at DESC`
+	rows, err := r.db.QueryContext(ctx, q, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.DeviceCalibration{}
+	for rows.Next() {
+		var cal models.DeviceCalibration
+		var ref, measured []byte
+		if err := rows.Scan(&cal.ID, &cal.DeviceID, &cal.PerformedAt, &cal.Technician, &ref, &measured, &cal.Passed, &cal.Notes,
+			&cal.CreatedBy, &cal.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(ref, &cal.PhantomReference); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(measured, &cal.MeasuredValues); err != nil {
+			return nil, err
+		}
+		out = append(out, cal)
+	}
+	return out, rows.Err()
+}
+
+// GetPolicy returns the calibration policy of a facility, or nil when the
+// facility has none.
+func (r *CalibrationRepository) GetPolicy(ctx context.Context, facility string) (*models.CalibrationPolicy, error) {
+	var p models.CalibrationPolicy
+	q := `SELECT facility, interval_days, enforcement FROM facility_calibration_policies WHERE facility = $1`
+	err := r.db.QueryRowContext(ctx, q, facility).Scan(&p.Facility, &p.IntervalDays, &p.Enforcement)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPolicies returns every configured facility policy keyed by facility.
+func (r *CalibrationRepository) ListPolicies(ctx context.Context) (map[string]models.CalibrationPolicy, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT facility, interval_days, enforcement FROM facility_calibration_policies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]models.CalibrationPolicy{}
+	for rows.Next() {
+		var p models.CalibrationPolicy
+		if err := rows.Scan(&p.Facility, &p.IntervalDays, &p.Enforcement); err != nil {
+			return nil, err
+		}
+		out[p.Facility] = p
+	}
+	return out, rows.Err()
+}
+
+// UpsertPolicy creates or replaces the policy of a facility.
+func (r *CalibrationRepository) UpsertPolicy(ctx context.Context, p models.CalibrationPolicy) error {
+	q := `INSERT INTO facility_calibration_policies (facility, interval_days, enforcement) VALUES ($1, $2, $3)
+		ON CONFLICT (facility) DO UPDATE SET interval_days = EXCLUDED.interval_days, enforcement = EXCLUDED.enforcement, updated_at = now()`
+	_, err := r.db.ExecContext(ctx, q, p.Facility, p.IntervalDays, p.Enforcement)
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strconv"
 	"time"
 
 	"edora/backend/internal/models"
@@ -22,6 +23,62 @@ type DeviceRepo interface {
 	GetBySerial(ctx context.Context, serial string) (*models.Device, error)
 	UpdateLastSeen(ctx context.Context, id string, t time.Time) error
 	CountActive(ctx context.Context, since time.Duration) (int, error)
+	SetSecret(ctx context.Context, id, secret string) error
+	UseNonce(ctx context.Context, deviceID, nonce string, window time.Duration) (bool, error)
+	Create(ctx context.Context, dev *models.Device) (string, error)
+	GetByID(ctx context.Context, id string) (*models.Device, error)
+	Update(ctx context.Context, dev *models.Device) error
+	SetStatus(ctx context.Context, id, status string) error
+	Decommission(ctx context.Context, id string) error
+	CountReadingsSince(ctx context.Context, id string, t time.Time) (int, error)
+	List(ctx context.Context, f DeviceFilter) ([]models.DeviceSummary, int, error)
+	MarkOffline(ctx context.Context, silence time.Duration) ([]string, error)
+	StatusHistory(ctx context.Context, id string, since time.Time) (string, []models.DeviceStatusChange, error)
+	InsertTelemetry(ctx context.Context, deviceID string, t *models.DeviceTelemetry) error
+	ListTelemetry(ctx context.Context, deviceID string, since time.Time, limit int) ([]models.DeviceTelemetry, error)
+}
+
+// DeviceFilter selects and orders devices for List.
+type DeviceFilter struct {
+	Statuses []string
+	// LastSeenAsc sorts oldest first; the default is most recently seen first.
+	LastSeenAsc bool
+	Limit       int
+	Offset      int
+}
+
+// ErrSerialTaken is returned when registering a serial number that exists.
+var ErrSerialTaken = errors.New("serial number already registered")
+
+const deviceColumns = `id, serial_number, COALESCE(name, ''), COALESCE(facility, ''), status, COALESCE(firmware_version, ''), last_seen,
+	decommissioned_at, created_at, COALESCE(updated_at, created_at), COALESCE(api_secret, ''),
+	COALESCE(hardware_revision, 0),
+	(SELECT MAX(c.performed_at) FROM device_calibrations c WHERE c.device_id = devices.id AND c.passed),
+	lsc_bmd, calibration_baseline_at`
+
+func scanDevice(row rowScanner) (*models.Device, error) {
+	var dev models.Device
+	var lastSeen, decommissionedAt, lastCalibrated, baseline sql.NullTime
+	var lsc sql.NullFloat64
+	if err := row.Scan(&dev.ID, &dev.SerialNumber, &dev.Name, &dev.Facility, &dev.Status, &dev.FirmwareVersion, &lastSeen,
+		&decommissionedAt, &dev.CreatedAt, &dev.UpdatedAt, &dev.Secret,
+		&dev.HardwareRevision, &lastCalibrated, &lsc, &baseline); err != nil {
+		return nil, err
+	}
+	dev.LSC = nullFloat(lsc)
+	if lastSeen.Valid {
+		dev.LastSeen = lastSeen.Time
+	}
+	if decommissionedAt.Valid {
+		dev.DecommissionedAt = &decommissionedAt.Time
+	}
+	if lastCalibrated.Valid {
+		dev.LastCalibratedAt = &lastCalibrated.Time
+	}
+	if baseline.Valid {
+		dev.CalibrationBaselineAt = &baseline.Time
+	}
+	return &dev, nil
 }
 
 func (d *DeviceRepository) GetBySerial(ctx context.Context, serial string) (*models.Device, error) {
@@ -31,9 +88,10 @@ func (d *DeviceRepository) GetBySerial(ctx context.Context, serial string) (*mod
 			ID:           "mock-device-id-123",
 			SerialNumber: serial, // Return serial yang sama dengan request
 			Name:         "Mock Device Unit",
-			Status:       "active",
+			Status:       models.DeviceOnline,
 			LastSeen:     time.Now(),
 			CreatedAt:    time.Now(),
+			Secret:       "mock-device-secret",
 		}, nil
 	}
 
@@ -42,20 +100,12 @@ func (d *DeviceRepository) GetBySerial(ctx context.Context, serial string) (*mod
 	if !ok {
 		return nil, errors.New("unsupported db type")
 	}
-	q := `SELECT id, serial_number, name, status, last_seen, created_at FROM devices WHERE serial_number = $1 LIMIT 1`
-	row := db.QueryRowContext(ctx, q, serial)
-	var dev models.Device
-	var lastSeen sql.NullTime
-	if err := row.Scan(&dev.ID, &dev.SerialNumber, &dev.Name, &dev.Status, &lastSeen, &dev.CreatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
-	}
-	if lastSeen.Valid {
-		dev.LastSeen = lastSeen.Time
+	q := `SELECT ` + deviceColumns + ` FROM devices WHERE serial_number = $1 LIMIT 1`
+	dev, err := scanDevice(db.QueryRowContext(ctx, q, serial))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	return &dev, nil
+	return dev, err
 }
 
 func (d *DeviceRepository) UpdateLastSeen(ctx context.Context, id string, t time.Time) error {
@@ -66,7 +116,10 @@ func (d *DeviceRepository) UpdateLastSeen(ctx context.Context, id string, t time
 	if !ok {
 		return errors.New("unsupported db type")
 	}
-	q := `UPDATE devices SET last_seen = $1, status = 'online' WHERE id = $2`
+	// maintenance/decommissioned are set by people, not by traffic
+	q := `UPDATE devices SET last_seen = $1,
+		status = CASE WHEN status IN ('maintenance', 'decommissioned') THEN status ELSE 'online' END
+		WHERE id = $2`
 	_, err := db.ExecContext(ctx, q, t, id)
 	return err
 }
@@ -89,3 +142,332 @@ func (d *DeviceRepository) CountActive(ctx context.Context, since time.Duration)
 	}
 	return count, nil
 }
+
+// SetSecret stores a new signing secret for the device.
+func (d *DeviceRepository) SetSecret(ctx context.Context, id, secret string) error {
+	if d.db == nil {
+		return nil // Mock success
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return errors.New("unsupported db type")
+	}
+	q := `UPDATE devices SET api_secret = $1, secret_rotated_at = now() WHERE id = $2`
+	_, err := db.ExecContext(ctx, q, secret, id)
+	return err
+}
+
+// UseNonce records a request nonce for the device. It returns false when the
+// nonce was already used (replay). Nonces older than window are purged.
+func (d *DeviceRepository) UseNonce(ctx context.Context, deviceID, nonce string, window time.Duration) (bool, error) {
+	if d.db == nil {
+		return true, nil // Mock: semua nonce dianggap baru
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return false, errors.New("unsupported db type")
+	}
+
+	// best-effort cleanup of nonces that can no longer be replayed
+	_, _ = db.ExecContext(ctx, `DELETE FROM device_nonces WHERE created_at < $1`, time.Now().Add(-window))
+
+	res, err := db.ExecContext(ctx, `INSERT INTO device_nonces (device_id, nonce) VALUES ($1, $2) ON CONFLICT DO NOTHING`, deviceID, nonce)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Create registers a new device.
+func (d *DeviceRepository) Create(ctx context.Context, dev *models.Device) (string, error) {
+	if d.db == nil {
+		dev.ID = generateID()
+		dev.CreatedAt = time.Now()
+		dev.UpdatedAt = dev.CreatedAt
+		return dev.ID, nil
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return "", errors.New("unsupported db type")
+	}
+	q := `INSERT INTO devices (serial_number, name, facility, status, api_secret, secret_rotated_at, hardware_revision)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), now(), NULLIF($6, 0))
+		ON CONFLICT (serial_number) DO NOTHING
+		RETURNING id, created_at, updated_at`
+	err := db.QueryRowContext(ctx, q, dev.SerialNumber, dev.Name, dev.Facility, dev.Status, dev.Secret, dev.HardwareRevision).Scan(&dev.ID, &dev.CreatedAt, &dev.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return "", ErrSerialTaken
+	}
+	if err != nil {
+		return "", err
+	}
+	return dev.ID, nil
+}
+
+func (d *DeviceRepository) GetByID(ctx context.Context, id string) (*models.Device, error) {
+	if d.db == nil {
+		return nil, nil
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("unsupported db type")
+	}
+	dev, err := scanDevice(db.QueryRowContext(ctx, `SELECT `+deviceColumns+` FROM devices WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return dev, err
+}
+
+// Update changes the descriptive fields (name, facility) of a device.
+func (d *DeviceRepository) Update(ctx context.Context, dev *models.Device) error {
+	if d.db == nil {
+		return nil // Mock success
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return errors.New("unsupported db type")
+	}
+	// LSC is only changed when given
+	q := `UPDATE devices SET name = $1, facility = $2, lsc_bmd = COALESCE($3, lsc_bmd), updated_at = now()
+		WHERE id = $4 AND status <> 'decommissioned'`
+	return execOne(ctx, db, q, dev.Name, dev.Facility, dev.LSC, dev.ID)
+}
+
+// SetStatus changes the status of a device that is not decommissioned.
+func (d *DeviceRepository) SetStatus(ctx context.Context, id, status string) error {
+	if d.db == nil {
+		return nil // Mock success
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return errors.New("unsupported db type")
+	}
+	q := `UPDATE devices SET status = $1, updated_at = now() WHERE id = $2 AND status <> 'decommissioned'`
+	return execOne(ctx, db, q, status, id)
+}
+
+// Decommission retires a device for good and drops its credentials.
+func (d *DeviceRepository) Decommission(ctx context.Context, id string) error {
+	if d.db == nil {
+		return nil // Mock success
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return errors.New("unsupported db type")
+	}
+	q := `UPDATE devices SET status = 'decommissioned', decommissioned_at = now(), api_secret = NULL, updated_at = now()
+		WHERE id = $1 AND status <> 'decommissioned'`
+	return execOne(ctx, db, q, id)
+}
+
+// CountReadingsSince counts readings uploaded by the device since t.
+func (d *DeviceRepository) CountReadingsSince(ctx context.Context, id string, t time.Time) (int, error) {
+	if d.db == nil {
+		return 0, nil
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return 0, errors.New("unsupported db type")
+	}
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM readings WHERE device_id = $1 AND created_at >= $2`, id, t).Scan(&n)
+	return n, err
+}
+
+// List returns one page of devices with their reading count since the start
+// of today, plus the total number of devices matching the filter.
+func (d *DeviceRepository) List(ctx context.Context, f DeviceFilter) ([]models.DeviceSummary, int, error) {
+	if d.db == nil {
+		return []models.DeviceSummary{}, 0, nil
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return nil, 0, errors.New("unsupported db type")
+	}
+
+	where := ``
+	args := []any{}
+	if len(f.Statuses) > 0 {
+		args = append(args, f.Statuses)
+		where = `WHERE status = ANY($1)`
+	}
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM devices `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := `DESC`
+	if f.LastSeenAsc {
+		order = `ASC`
+	}
+	args = append(args, f.Limit, f.Offset)
+	q := `SELECT ` + deviceColumns + `,
+			(SELECT COUNT(*) FROM readings r WHERE r.device_id = devices.id AND r.created_at >= date_trunc('day', now()))
+		FROM devices ` + where + `
+		ORDER BY last_seen ` + order + ` NULLS LAST, serial_number
+		LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := []models.DeviceSummary{}
+	for rows.Next() {
+		var sum models.DeviceSummary
+		dev, err := scanDevice(extraScanner{rows, []any{&sum.ReadingsToday}})
+		if err != nil {
+			return nil, 0, err
+		}
+		sum.Device = *dev
+		out = append(out, sum)
+	}
+	return out, total, rows.Err()
+}
+
+// MarkOffline flips online devices that have been silent longer than silence
+// to offline and returns their serial numbers.
+func (d *DeviceRepository) MarkOffline(ctx context.Context, silence time.Duration) ([]string, error) {
+	if d.db == nil {
+		return nil, nil
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("unsupported db type")
+	}
+	q := `UPDATE devices SET status = 'offline', updated_at = now()
+		WHERE status = 'online' AND (last_seen IS NULL OR last_seen < $1)
+		RETURNING serial_number`
+	rows, err := db.QueryContext(ctx, q, time.Now().Add(-silence))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		serials = append(serials, s)
+	}
+	return serials, rows.Err()
+}
+
+// StatusHistory returns the status the device had at since (empty when it
+// did not exist yet) and every status change after since, oldest first.
+func (d *DeviceRepository) StatusHistory(ctx context.Context, id string, since time.Time) (string, []models.DeviceStatusChange, error) {
+	if d.db == nil {
+		return "", nil, nil
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return "", nil, errors.New("unsupported db type")
+	}
+
+	var initial string
+	q := `SELECT to_status FROM device_status_history WHERE device_id = $1 AND changed_at <= $2 ORDER BY changed_at DESC, id DESC LIMIT 1`
+	if err := db.QueryRowContext(ctx, q, id, since).Scan(&initial); err != nil && err != sql.ErrNoRows {
+		return "", nil, err
+	}
+
+	q = `SELECT COALESCE(from_status, ''), to_status, changed_at FROM device_status_history
+		WHERE device_id = $1 AND changed_at > $2 ORDER BY changed_at, id`
+	rows, err := db.QueryContext(ctx, q, id, since)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.DeviceStatusChange{}
+	for rows.Next() {
+		var ch models.DeviceStatusChange
+		if err := rows.Scan(&ch.FromStatus, &ch.ToStatus, &ch.ChangedAt); err != nil {
+			return "", nil, err
+		}
+		changes = append(changes, ch)
+	}
+	return initial, changes, rows.Err()
+}
+
+// InsertTelemetry stores a heartbeat sample, remembers the reported firmware
+// version and hardware revision on the device and marks a firmware rollout
+// to the reported version as installed.
+func (d *DeviceRepository) InsertTelemetry(ctx context.Context, deviceID string, t *models.DeviceTelemetry) error {
+	if d.db == nil {
+		return nil // Mock success
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return errors.New("unsupported db type")
+	}
+	q := `INSERT INTO device_telemetry (device_id, recorded_at, firmware_version, battery_level, temperature, free_storage_bytes)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)`
+	if _, err := db.ExecContext(ctx, q, deviceID, t.RecordedAt, t.FirmwareVersion, t.BatteryLevel, t.Temperature, t.FreeStorageBytes); err != nil {
+		return err
+	}
+	if t.FirmwareVersion == "" && t.HardwareRevision == nil {
+		return nil
+	}
+	q = `UPDATE devices SET firmware_version = COALESCE(NULLIF($1, ''), firmware_version),
+		hardware_revision = COALESCE($2, hardware_revision) WHERE id = $3`
+	if _, err := db.ExecContext(ctx, q, t.FirmwareVersion, t.HardwareRevision, deviceID); err != nil {
+		return err
+	}
+	if t.FirmwareVersion != "" {
+		q = `UPDATE device_firmware_updates u SET installed_at = $3
+			FROM firmware_releases r
+			WHERE u.release_id = r.id AND u.device_id = $1 AND r.version = $2 AND u.installed_at IS NULL`
+		_, err := db.ExecContext(ctx, q, deviceID, t.FirmwareVersion, t.RecordedAt)
+		return err
+	}
+	return nil
+}
+
+// ListTelemetry returns heartbeat samples recorded since t, oldest first.
+func (d *DeviceRepository) ListTelemetry(ctx context.Context, deviceID string, since time.Time, limit int) ([]models.DeviceTelemetry, error) {
+	if d.db == nil {
+		return []models.DeviceTelemetry{}, nil
+	}
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("unsupported db type")
+	}
+	q := `SELECT recorded_at, COALESCE(firmware_version, ''), battery_level, temperature, free_storage_bytes FROM (
+			SELECT * FROM device_telemetry WHERE device_id = $1 AND recorded_at >= $2 ORDER BY recorded_at DESC LIMIT $3
+		) t ORDER BY recorded_at`
+	rows, err := db.QueryContext(ctx, q, deviceID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.DeviceTelemetry{}
+	for rows.Next() {
+		var t models.DeviceTelemetry
+		var battery, temp sql.NullFloat64
+		var storage sql.NullInt64
+		if err := rows.Scan(&t.RecordedAt, &t.FirmwareVersion, &battery, &temp, &storage); err != nil {
+			return nil, err
+		}
+		if battery.Valid {
+			t.BatteryLevel = &battery.Float64
+		}
+		if temp.Valid {
+			t.Temperature = &temp.Float64
+		}
+		if storage.Valid {
+			t.FreeStorageBytes = &storage.Int64
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"edora/backend/internal/models"
+)
+
+// ErrVersionTaken is returned when publishing a firmware version that exists.
+var ErrVersionTaken = errors.New("firmware version already released")
+
+type FirmwareRepository struct {
+	db *sql.DB
+}
+
+func NewFirmwareRepository(db *sql.DB) *FirmwareRepository {
+	return &FirmwareRepository{db: db}
+}
+
+const firmwareColumns = `id, version, checksum_sha256, size_bytes, file_name, COALESCE(release_notes, ''),
+	min_hardware_revision, max_hardware_revision, rollout_percent, COALESCE(created_by::text, ''), created_at, COALESCE(updated_at, created_at)`
+
+func scanFirmware(row rowScanner) (*models.FirmwareRelease, error) {
+	var rel models.FirmwareRelease
+	var minHW, maxHW sql.NullInt64
+	if err := row.Scan(&rel.ID, &rel.Version, &rel.ChecksumSHA256, &rel.SizeBytes, &rel.FileName, &rel.ReleaseNotes,
+		&minHW, &maxHW, &rel.RolloutPercent, &rel.CreatedBy, &rel.CreatedAt, &rel.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if minHW.Valid {
+		v := int(minHW.Int64)
+		rel.MinHardwareRevision = &v
+	}
+	if maxHW.Valid {
+		v := int(maxHW.Int64)
+		rel.MaxHardwareRevision = &v
+	}
+	return &rel, nil
+}
+
+// Create registers a release whose binary is already stored.
+func (r *FirmwareRepository) Create(ctx context.Context, rel *models.FirmwareRelease) error {
+	q := `INSERT INTO firmware_releases (version, checksum_sha256, size_bytes, file_name, release_notes,
+			min_hardware_revision, max_hardware_revision, rollout_percent, created_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, NULLIF($9, '')::uuid)
+		ON CONFLICT (version) DO NOTHING
+		RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, q, rel.Version, rel.ChecksumSHA256, rel.SizeBytes, rel.FileName, rel.ReleaseNotes,
+		rel.MinHardwareRevision, rel.MaxHardwareRevision, rel.RolloutPercent, rel.CreatedBy).Scan(&rel.ID, &rel.CreatedAt, &rel.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVersionTaken
+	}
+	return err
+}
+
+// GetByVersion returns a release, or nil when the version does not exist.
+func (r *FirmwareRepository) GetByVersion(ctx context.Context, version string) (*models.FirmwareRelease, error) {
+	rel, err := scanFirmware(r.db.QueryRowContext(ctx, `SELECT `+firmwareColumns+` FROM firmware_releases WHERE version = $1`, version))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return rel, err
+}
+
+// List returns all releases, newest first.
+func (r *FirmwareRepository) List(ctx context.Context) ([]models.FirmwareRelease, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+firmwareColumns+` FROM firmware_releases ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.FirmwareRelease{}
+	for rows.Next() {
+		rel, err := scanFirmware(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *rel)
+	}
+	return out, rows.Err()
+}
+
+func (r *FirmwareRepository) SetRollout(ctx context.Context, version string, percent int) error {
+	return execOne(ctx, r.db, `UPDATE firmware_releases SET rollout_percent = $2, updated_at = now() WHERE version = $1`, version, percent)
+}
+
+// RecordOffer notes that the release was offered to the device.
+func (r *FirmwareRepository) RecordOffer(ctx context.Context, deviceID, releaseID, fromVersion string) error {
+	q := `INSERT INTO device_firmware_updates (device_id, release_id, from_version) VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (device_id, release_id) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, q, deviceID, releaseID, fromVersion)
+	return err
+}
+
+// RecordDownload notes the first download of the release by the device.
+func (r *FirmwareRepository) RecordDownload(ctx context.Context, deviceID, releaseID string) error {
+	q := `INSERT INTO device_firmware_updates (device_id, release_id, downloaded_at) VALUES ($1, $2, now())
+		ON CONFLICT (device_id, release_id) DO UPDATE SET downloaded_at = COALESCE(device_firmware_updates.downloaded_at, now())`
+	_, err := r.db.ExecContext(ctx, q, deviceID, releaseID)
+	return err
+}
+
+const firmwareUpdateQuery = `SELECT u.device_id, d.serial_number, r.version, COALESCE(u.from_version, ''), u.offered_at, u.downloaded_at, u.installed_at
+	FROM device_firmware_updates u
+	JOIN devices d ON d.id = u.device_id
+	JOIN firmware_releases r ON r.id = u.release_id`
+
+// ReleaseProgress returns the rollout state of a release on every device it
+// was offered to.
+func (r *FirmwareRepository) ReleaseProgress(ctx context.Context, releaseID string) ([]models.DeviceFirmwareUpdate, error) {
+	return r.listUpdates(ctx, firmwareUpdateQuery+` WHERE u.release_id = $1 ORDER BY d.serial_number`, releaseID)
+}
+
+// DeviceUpdates returns every release offered to a device, newest first.
+func (r *FirmwareRepository) DeviceUpdates(ctx context.Context, deviceID string) ([]models.DeviceFirmwareUpdate, error) {
+	return r.listUpdates(ctx, firmwareUpdateQuery+` WHERE u.device_id = $1 ORDER BY u.offered_at DESC`, deviceID)
+}
+
+func (r *FirmwareRepository) listUpdates(ctx context.Context, q string, arg string) ([]models.DeviceFirmwareUpdate, error) {
+	rows, err := r.db.QueryContext(ctx, q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.DeviceFirmwareUpdate{}
+	for rows.Next() {
+		var u models.DeviceFirmwareUpdate
+		var downloaded, installed sql.NullTime
+		if err := rows.Scan(&u.DeviceID, &u.SerialNumber, &u.Version, &u.FromVersion, &u.OfferedAt, &downloaded, &installed); err != nil {
+			return nil, err
+		}
+		if downloaded.Valid {
+			u.DownloadedAt = &downloaded.Time
+		}
+		if installed.Valid {
+			u.InstalledAt = &installed.Time
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
@@ -5,12 +5,25 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"log"
 	"time"
 
 	"edora/backend/internal/models"
 )
 
+// PatientRepo defines the patient storage used by services and tests.
+type PatientRepo interface {
+	CreatePatient(ctx context.Context, p *models.Patient) (string, error)
+	ListPatients(ctx context.Context) ([]models.Patient, error)
+	GetPatient(ctx context.Context, id string) (*models.Patient, error)
+	GetRiskFactors(ctx context.Context, patientID string) (*models.RiskFactors, error)
+	UpsertRiskFactors(ctx context.Context, rf *models.RiskFactors) error
+	UpdatePatient(ctx context.Context, p *models.Patient) error
+	HasSignedRecords(ctx context.Context, id string) (bool, error)
+	DeletePatient(ctx context.Context, id string) error
+}
+
 type PatientRepository struct {
 	db *sql.DB
 }
@@ -98,6 +111,57 @@ func (r *PatientRepository) ListPatients(ctx context.Context) ([]models.Patient,
 	return patients, nil
 }
 
+// GetPatient returns the patient with id, or ErrNotFound.
+func (r *PatientRepository) GetPatient(ctx context.Context, id string) (*models.Patient, error) {
+	var p models.Patient
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, nik, name, gender, birth_date, address, created_at, updated_at
+		FROM patients WHERE id = $1
+	`, id).Scan(&p.ID, &p.NIK, &p.Name, &p.Gender, &p.BirthDate, &p.Address, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetRiskFactors returns the fracture risk questionnaire of a patient, or
+// nil when it was never filled in.
+func (r *PatientRepository) GetRiskFactors(ctx context.Context, patientID string) (*models.RiskFactors, error) {
+	rf := models.RiskFactors{PatientID: patientID}
+	var bmi sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT prior_fracture, parental_hip_fracture, current_smoking, glucocorticoids,
+			rheumatoid_arthritis, alcohol, bmi, COALESCE(updated_by::text, ''), updated_at
+		FROM patient_risk_factors WHERE patient_id = $1
+	`, patientID).Scan(&rf.PriorFracture, &rf.ParentalHipFracture, &rf.CurrentSmoking, &rf.Glucocorticoids,
+		&rf.RheumatoidArthritis, &rf.Alcohol, &bmi, &rf.UpdatedBy, &rf.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rf.BMI = nullFloat(bmi)
+	return &rf, nil
+}
+
+// UpsertRiskFactors creates or replaces the questionnaire of a patient.
+func (r *PatientRepository) UpsertRiskFactors(ctx context.Context, rf *models.RiskFactors) error {
+	q := `INSERT INTO patient_risk_factors (patient_id, prior_fracture, parental_hip_fracture, current_smoking,
+			glucocorticoids, rheumatoid_arthritis, alcohol, bmi, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::uuid)
+		ON CONFLICT (patient_id) DO UPDATE SET prior_fracture = EXCLUDED.prior_fracture,
+			parental_hip_fracture = EXCLUDED.parental_hip_fracture, current_smoking = EXCLUDED.current_smoking,
+			glucocorticoids = EXCLUDED.glucocorticoids, rheumatoid_arthritis = EXCLUDED.rheumatoid_arthritis,
+			alcohol = EXCLUDED.alcohol, bmi = EXCLUDED.bmi, updated_by = EXCLUDED.updated_by, updated_at = now()
+		RETURNING updated_at`
+	return r.db.QueryRowContext(ctx, q, rf.PatientID, rf.PriorFracture, rf.ParentalHipFracture, rf.CurrentSmoking,
+		rf.Glucocorticoids, rf.RheumatoidArthritis, rf.Alcohol, rf.BMI, rf.UpdatedBy).Scan(&rf.UpdatedAt)
+}
+
 func (r *PatientRepository) UpdatePatient(ctx context.Context, p *models.Patient) error {
 	p.UpdatedAt = time.Now()
 	query := `
@@ -117,6 +181,14 @@ func (r *PatientRepository) UpdatePatient(ctx context.Context, p *models.Patient
 	return err
 }
 
+// HasSignedRecords reports whether the patient has signed scan results,
+// which may not be deleted.
+func (r *PatientRepository) HasSignedRecords(ctx context.Context, id string) (bool, error) {
+	var signed bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM readings WHERE patient_id = $1 AND review_status = 'signed')`, id).Scan(&signed)
+	return signed, err
+}
+
 func (r *PatientRepository) DeletePatient(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, `DELETE FROM patients WHERE id = $1`, id)
 	return err
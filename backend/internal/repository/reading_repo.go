@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"sort"
 	"strings"
 	"sync" // Tambah sync untuk keamanan data
 	"time"
@@ -20,6 +21,7 @@ type ReadingRepository struct {
 	mu                 sync.Mutex
 	mockReadings       []models.Reading
 	mockMedicalRecords []models.MedicalRecord
+	mockReviews        map[string]models.Review
 }
 
 func NewReadingRepository(db interface{}) *ReadingRepository {
@@ -37,7 +39,7 @@ func NewReadingRepository(db interface{}) *ReadingRepository {
 	initialMR := []models.MedicalRecord{}
 	if db == nil {
 		initialMR = []models.MedicalRecord{
-			{ID: 1, PatientID: "patient-1", TScore: -0.5, Diagnosis: "Normal", ScanDate: time.Now(), Notes: "demo"},
+			{ID: "1", PatientID: "patient-1", Source: models.SourceManual, TScore: -0.5, Diagnosis: "Normal", ScanDate: time.Now(), Notes: "demo"},
 		}
 	}
 
@@ -45,22 +47,40 @@ func NewReadingRepository(db interface{}) *ReadingRepository {
 		db:                 db,
 		mockReadings:       initialData,
 		mockMedicalRecords: initialMR,
+		mockReviews:        map[string]models.Review{},
 	}
 }
 
 type ReadingRepo interface {
-	CreateReading(ctx context.Context, rd *models.Reading) (string, error)
+	CreateReading(ctx context.Context, rd *models.Reading) (id string, created bool, err error)
+	CreateReadings(ctx context.Context, rds []*models.Reading) ([]ReadingInsert, error)
 	GetStats(ctx context.Context) (int, map[string]int, error)
-	CreateMedicalRecord(ctx context.Context, mr *models.MedicalRecord) (int, error)
+	ListDiscrepancies(ctx context.Context, since time.Time, limit int) ([]models.Reading, error)
+	CreateMedicalRecord(ctx context.Context, mr *models.MedicalRecord) (string, error)
 	GetPatientRecords(ctx context.Context, patientID string) ([]models.MedicalRecord, error)
+	GetRecord(ctx context.Context, id string) (*models.MedicalRecord, error)
+	Worklist(ctx context.Context, doctorID string, limit int) ([]models.MedicalRecord, error)
+	SetReview(ctx context.Context, id, from string, rv models.Review) error
+	SetFractureRisk(ctx context.Context, mr *models.MedicalRecord) error
 }
 
-func (r *ReadingRepository) CreateReading(ctx context.Context, rd *models.Reading) (string, error) {
+// CreateReading inserts a reading. When the device already synced a reading
+// with the same ClientReadingID nothing is inserted and the ID of the
+// existing reading is returned with created == false.
+func (r *ReadingRepository) CreateReading(ctx context.Context, rd *models.Reading) (string, bool, error) {
 	// MODE MOCK (DB MATI)
 	if r.db == nil {
 		r.mu.Lock()
 		defer r.mu.Unlock()
 
+		if rd.ClientReadingID != "" {
+			for _, existing := range r.mockReadings {
+				if existing.DeviceID == rd.DeviceID && existing.ClientReadingID == rd.ClientReadingID {
+					return existing.ID, false, nil
+				}
+			}
+		}
+
 		// Generate ID
 		b := make([]byte, 16)
 		rand.Read(b)
@@ -74,25 +94,98 @@ func (r *ReadingRepository) CreateReading(ctx context.Context, rd *models.Readin
 		// SIMPAN KE MEMORI (RAM)
 		r.mockReadings = append(r.mockReadings, *rd)
 
-		return rd.ID, nil
+		return rd.ID, true, nil
 	}
 
 	// MODE REAL DB
 	db, ok := r.db.(*sql.DB)
 	if !ok {
-		return "", errors.New("unsupported db type")
+		return "", false, errors.New("unsupported db type")
 	}
+	return insertReading(ctx, db, rd)
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
-	q := `INSERT INTO readings (device_id, patient_id, doctor_id, bmd_result, t_score, classification, raw_signal_data, latitude, longitude, created_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id`
+func insertReading(ctx context.Context, db queryRower, rd *models.Reading) (string, bool, error) {
+	q := `INSERT INTO readings (device_id, patient_id, doctor_id, bmd_result, t_score, classification, raw_signal_data, latitude, longitude, created_at, calibration_overdue, client_reading_id,
+			algorithm_version, client_bmd_result, client_t_score, bmd_discrepancy, t_score_discrepancy, discrepancy_flagged,
+			diagnosis_rationale, rule_set_version, site, z_score, reference_version,
+			major_fracture_risk, hip_fracture_risk, treatment_recommended, risk_model_version)
+		VALUES ($1,$2,NULLIF($3, '')::uuid,$4,$5,$6,$7,$8,$9,$10,$11,NULLIF($12, ''),NULLIF($13, ''),$14,$15,$16,$17,$18,NULLIF($19, ''),NULLIF($20, ''),
+			NULLIF($21, ''),$22,NULLIF($23, ''),$24,$25,$26,NULLIF($27, ''))
+		ON CONFLICT (device_id, client_reading_id) DO NOTHING
+		RETURNING id`
 	raw := rd.RawSignalData
 	if len(raw) == 0 {
 		raw = json.RawMessage("[]")
 	}
 	var id string
-	if err := db.QueryRowContext(ctx, q, rd.DeviceID, rd.PatientID, rd.DoctorID, rd.BMDResult, rd.TScore, rd.Classification, raw, rd.Latitude, rd.Longitude, rd.CreatedAt).Scan(&id); err != nil {
-		return "", err
+	err := db.QueryRowContext(ctx, q, rd.DeviceID, rd.PatientID, rd.DoctorID, rd.BMDResult, rd.TScore, rd.Classification, raw, rd.Latitude, rd.Longitude, rd.CreatedAt, rd.CalibrationOverdue, rd.ClientReadingID,
+		rd.AlgorithmVersion, rd.ClientBMDResult, rd.ClientTScore, rd.BMDDiscrepancy, rd.TScoreDiscrepancy, rd.DiscrepancyFlagged,
+		rd.DiagnosisRationale, rd.RuleSetVersion, rd.Site, rd.ZScore, rd.ReferenceVersion,
+		rd.MajorFractureRisk, rd.HipFractureRisk, rd.TreatmentRecommended, rd.RiskModelVersion).Scan(&id)
+	if err == sql.ErrNoRows {
+		// retry of a reading we already have
+		err = db.QueryRowContext(ctx, `SELECT id FROM readings WHERE device_id = $1 AND client_reading_id = $2`, rd.DeviceID, rd.ClientReadingID).Scan(&id)
+		return id, false, err
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return id, true, nil
+}
+
+// ReadingInsert is the outcome of one reading in CreateReadings.
+type ReadingInsert struct {
+	ID      string
+	Created bool
+	Err     error
+}
+
+// CreateReadings inserts the readings in one transaction. Each insert runs
+// under its own savepoint, so a reading the database refuses (e.g. unknown
+// patient) is reported in its ReadingInsert without failing the others.
+// The returned error is only set when the transaction itself fails.
+func (r *ReadingRepository) CreateReadings(ctx context.Context, rds []*models.Reading) ([]ReadingInsert, error) {
+	out := make([]ReadingInsert, len(rds))
+	if r.db == nil {
+		for i, rd := range rds {
+			out[i].ID, out[i].Created, out[i].Err = r.CreateReading(ctx, rd)
+		}
+		return out, nil
+	}
+
+	db, ok := r.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("unsupported db type")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i, rd := range rds {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT reading_item`); err != nil {
+			return nil, err
+		}
+		out[i].ID, out[i].Created, out[i].Err = insertReading(ctx, tx, rd)
+		release := `RELEASE SAVEPOINT reading_item`
+		if out[i].Err != nil {
+			release = `ROLLBACK TO SAVEPOINT reading_item`
+		}
+		if _, err := tx.ExecContext(ctx, release); err != nil {
+			return nil, err
+		}
 	}
-	return id, nil
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 func (r *ReadingRepository) GetStats(ctx context.Context) (int, map[string]int, error) {
@@ -126,6 +219,13 @@ func (r *ReadingRepository) GetStats(ctx context.Context) (int, map[string]int,
 				}
 			}
 		}
+		// input manual ikut dihitung, sama seperti di DB
+		for _, mr := range r.mockMedicalRecords {
+			if !mr.ScanDate.Before(startOfDay) {
+				totalToday++
+				stats[mr.Diagnosis]++
+			}
+		}
 
 		return totalToday, stats, nil
 	}
@@ -159,52 +259,164 @@ func (r *ReadingRepository) GetStats(ctx context.Context) (int, map[string]int,
 	return total, stats, nil
 }
 
-// CreateMedicalRecord menyimpan record medis (mock atau DB)
-func (r *ReadingRepository) CreateMedicalRecord(ctx context.Context, mr *models.MedicalRecord) (int, error) {
+// ListDiscrepancies returns readings since the given time whose client
+// values differed from the server analysis beyond tolerance, newest first.
+// The raw signal is left out.
+func (r *ReadingRepository) ListDiscrepancies(ctx context.Context, since time.Time, limit int) ([]models.Reading, error) {
 	if r.db == nil {
 		r.mu.Lock()
 		defer r.mu.Unlock()
 
-		id := len(r.mockMedicalRecords) + 1
-		mr.ID = id
-		if mr.ScanDate.IsZero() {
-			mr.ScanDate = time.Now()
+		out := []models.Reading{}
+		for i := len(r.mockReadings) - 1; i >= 0 && len(out) < limit; i-- {
+			if rd := r.mockReadings[i]; rd.DiscrepancyFlagged && !rd.CreatedAt.Before(since) {
+				rd.RawSignalData = nil
+				out = append(out, rd)
+			}
 		}
-		r.mockMedicalRecords = append(r.mockMedicalRecords, *mr)
-		return id, nil
+		return out, nil
 	}
 
 	db, ok := r.db.(*sql.DB)
 	if !ok {
-		return 0, errors.New("unsupported db type")
+		return nil, errors.New("unsupported db type")
+	}
+
+	q := `SELECT id, COALESCE(device_id::text, ''), COALESCE(patient_id::text, ''), bmd_result, t_score, classification, created_at,
+			COALESCE(algorithm_version, ''), client_bmd_result, client_t_score, bmd_discrepancy, t_score_discrepancy
+		FROM readings WHERE discrepancy_flagged AND created_at >= $1
+		ORDER BY created_at DESC LIMIT $2`
+	rows, err := db.QueryContext(ctx, q, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.Reading{}
+	for rows.Next() {
+		rd := models.Reading{DiscrepancyFlagged: true}
+		var clientBMD, clientT, bmdDiff, tDiff sql.NullFloat64
+		if err := rows.Scan(&rd.ID, &rd.DeviceID, &rd.PatientID, &rd.BMDResult, &rd.TScore, &rd.Classification, &rd.CreatedAt,
+			&rd.AlgorithmVersion, &clientBMD, &clientT, &bmdDiff, &tDiff); err != nil {
+			return nil, err
+		}
+		rd.ClientBMDResult = nullFloat(clientBMD)
+		rd.ClientTScore = nullFloat(clientT)
+		rd.BMDDiscrepancy = nullFloat(bmdDiff)
+		rd.TScoreDiscrepancy = nullFloat(tDiff)
+		out = append(out, rd)
 	}
+	return out, rows.Err()
+}
 
-	q := `INSERT INTO medical_records (patient_id, t_score, diagnosis, notes, scan_date) VALUES ($1,$2,$3,$4,$5) RETURNING id, scan_date`
+// CreateMedicalRecord stores a manually entered scan result in readings.
+func (r *ReadingRepository) CreateMedicalRecord(ctx context.Context, mr *models.MedicalRecord) (string, error) {
+	mr.Source = models.SourceManual
 	if mr.ScanDate.IsZero() {
 		mr.ScanDate = time.Now()
 	}
-	var id int
-	var scanDate time.Time
-	if err := db.QueryRowContext(ctx, q, mr.PatientID, mr.TScore, mr.Diagnosis, mr.Notes, mr.ScanDate).Scan(&id, &scanDate); err != nil {
-		return 0, err
+
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		b := make([]byte, 16)
+		rand.Read(b)
+		mr.ID = hex.EncodeToString(b)
+		r.mockMedicalRecords = append(r.mockMedicalRecords, *mr)
+		return mr.ID, nil
+	}
+
+	db, ok := r.db.(*sql.DB)
+	if !ok {
+		return "", errors.New("unsupported db type")
+	}
+
+	q := `INSERT INTO readings (source, patient_id, doctor_id, device_id, t_score, classification, notes, created_at,
+			bmd_result, site, z_score, reference_version, diagnosis_rationale, rule_set_version,
+			major_fracture_risk, hip_fracture_risk, treatment_recommended, risk_model_version)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, (SELECT id FROM devices WHERE serial_number = $4), $5, $6, $7, $8,
+			$9, NULLIF($10, ''), $11, NULLIF($12, ''), $13, $14, $15, $16, $17, NULLIF($18, ''))
+		RETURNING id, created_at`
+	err := db.QueryRowContext(ctx, q, mr.Source, mr.PatientID, mr.DoctorID, mr.DeviceSerial, mr.TScore, mr.Diagnosis, mr.Notes, mr.ScanDate,
+		mr.BMD, mr.Site, mr.ZScore, mr.ReferenceVersion, mr.DiagnosisRationale, mr.RuleSetVersion,
+		mr.MajorFractureRisk, mr.HipFractureRisk, mr.TreatmentRecommended, mr.RiskModelVersion).Scan(&mr.ID, &mr.ScanDate)
+	if err != nil {
+		return "", err
+	}
+	return mr.ID, nil
+}
+
+// recordColumns selects a scan result from readings r joined with devices d.
+// A device reading without BMD stored 0.
+const recordColumns = `r.id, r.legacy_record_id, COALESCE(r.patient_id::text, ''), r.source, COALESCE(r.doctor_id::text, ''), r.t_score, r.classification,
+	r.created_at, COALESCE(r.notes, ''), COALESCE(d.serial_number, ''),
+	NULLIF(r.bmd_result, 0), COALESCE(r.site, ''), r.z_score, COALESCE(r.reference_version, ''),
+	COALESCE(r.diagnosis_rationale, ''), COALESCE(r.rule_set_version, ''),
+	r.major_fracture_risk, r.hip_fracture_risk, r.treatment_recommended, COALESCE(r.risk_model_version, ''),
+	r.review_status, COALESCE(r.review_notes, ''), COALESCE(r.reviewed_by::text, ''), r.reviewed_at,
+	COALESCE(r.signed_by::text, ''), r.signed_at`
+
+const recordFrom = ` FROM readings r LEFT JOIN devices d ON d.id = r.device_id `
+
+func scanRecord(row rowScanner) (*models.MedicalRecord, error) {
+	var rcd models.MedicalRecord
+	var bmd, z, major, hip sql.NullFloat64
+	var legacyID sql.NullInt64
+	var treat sql.NullBool
+	var reviewedAt, signedAt sql.NullTime
+	if err := row.Scan(&rcd.ID, &legacyID, &rcd.PatientID, &rcd.Source, &rcd.DoctorID, &rcd.TScore, &rcd.Diagnosis,
+		&rcd.ScanDate, &rcd.Notes, &rcd.DeviceSerial,
+		&bmd, &rcd.Site, &z, &rcd.ReferenceVersion, &rcd.DiagnosisRationale, &rcd.RuleSetVersion,
+		&major, &hip, &treat, &rcd.RiskModelVersion,
+		&rcd.ReviewStatus, &rcd.ReviewNotes, &rcd.ReviewedBy, &reviewedAt, &rcd.SignedBy, &signedAt); err != nil {
+		return nil, err
+	}
+	if legacyID.Valid {
+		id := int(legacyID.Int64)
+		rcd.LegacyID = &id
+	}
+	rcd.BMD, rcd.ZScore = nullFloat(bmd), nullFloat(z)
+	rcd.MajorFractureRisk, rcd.HipFractureRisk = nullFloat(major), nullFloat(hip)
+	if treat.Valid {
+		rcd.TreatmentRecommended = &treat.Bool
+	}
+	if reviewedAt.Valid {
+		rcd.ReviewedAt = &reviewedAt.Time
+	}
+	if signedAt.Valid {
+		rcd.SignedAt = &signedAt.Time
+	}
+	return &rcd, nil
+}
+
+func scanRecords(rows *sql.Rows) ([]models.MedicalRecord, error) {
+	defer rows.Close()
+	var records []models.MedicalRecord
+	for rows.Next() {
+		rcd, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *rcd)
 	}
-	mr.ID = id
-	mr.ScanDate = scanDate
-	return id, nil
+	return records, rows.Err()
 }
 
-// GetPatientRecords mengambil riwayat medical records untuk pasien
+// GetPatientRecords returns every scan result of a patient, synced and
+// manual, newest first.
 func (r *ReadingRepository) GetPatientRecords(ctx context.Context, patientID string) ([]models.MedicalRecord, error) {
 	if r.db == nil {
 		r.mu.Lock()
 		defer r.mu.Unlock()
 
 		out := []models.MedicalRecord{}
-		for _, mr := range r.mockMedicalRecords {
+		for _, mr := range r.mockRecords() {
 			if mr.PatientID == patientID {
 				out = append(out, mr)
 			}
 		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].ScanDate.After(out[j].ScanDate) })
 		return out, nil
 	}
 
@@ -212,21 +424,180 @@ func (r *ReadingRepository) GetPatientRecords(ctx context.Context, patientID str
 	if !ok {
 		return nil, errors.New("unsupported db type")
 	}
+	rows, err := db.QueryContext(ctx, `SELECT `+recordColumns+recordFrom+`WHERE r.patient_id = $1 ORDER BY r.created_at DESC`, patientID)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecords(rows)
+}
+
+// GetRecord returns one scan result, or ErrNotFound.
+func (r *ReadingRepository) GetRecord(ctx context.Context, id string) (*models.MedicalRecord, error) {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		for _, mr := range r.mockRecords() {
+			if mr.ID == id {
+				return &mr, nil
+			}
+		}
+		return nil, ErrNotFound
+	}
+
+	db, ok := r.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("unsupported db type")
+	}
+	if !isUUID(id) {
+		return nil, ErrNotFound
+	}
+	rcd, err := scanRecord(db.QueryRowContext(ctx, `SELECT `+recordColumns+recordFrom+`WHERE r.id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rcd, err
+}
+
+// Worklist returns the unsigned, unrejected scan results assigned to a
+// doctor, oldest first. An empty doctorID selects the unassigned queue.
+func (r *ReadingRepository) Worklist(ctx context.Context, doctorID string, limit int) ([]models.MedicalRecord, error) {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		out := []models.MedicalRecord{}
+		for _, mr := range r.mockRecords() {
+			if mr.DoctorID == doctorID && (mr.ReviewStatus == models.ReviewPending || mr.ReviewStatus == models.ReviewReviewed) {
+				out = append(out, mr)
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].ScanDate.Before(out[j].ScanDate) })
+		return out[:min(limit, len(out))], nil
+	}
 
-	rows, err := db.QueryContext(ctx, `SELECT id, t_score, diagnosis, scan_date, notes FROM medical_records WHERE patient_id = $1 ORDER BY scan_date DESC`, patientID)
+	db, ok := r.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("unsupported db type")
+	}
+	rows, err := db.QueryContext(ctx, `SELECT `+recordColumns+recordFrom+`
+		WHERE r.doctor_id IS NOT DISTINCT FROM NULLIF($1, '')::uuid AND r.review_status IN ('pending_review', 'reviewed')
+		ORDER BY r.created_at LIMIT $2`, doctorID, limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanRecords(rows)
+}
 
-	var records []models.MedicalRecord
-	for rows.Next() {
-		var rcd models.MedicalRecord
-		rcd.PatientID = patientID
-		if err := rows.Scan(&rcd.ID, &rcd.TScore, &rcd.Diagnosis, &rcd.ScanDate, &rcd.Notes); err != nil {
-			continue
+// SetReview moves a scan result from the review status from to rv. It
+// returns ErrNotFound when the result does not exist or is no longer in
+// status from.
+func (r *ReadingRepository) SetReview(ctx context.Context, id, from string, rv models.Review) error {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		for _, mr := range r.mockRecords() {
+			if mr.ID == id && mr.ReviewStatus == from {
+				r.mockReviews[id] = rv
+				return nil
+			}
 		}
-		records = append(records, rcd)
+		return ErrNotFound
+	}
+
+	db, ok := r.db.(*sql.DB)
+	if !ok {
+		return errors.New("unsupported db type")
+	}
+	if !isUUID(id) {
+		return ErrNotFound
+	}
+	q := `UPDATE readings SET review_status = $1, review_notes = NULLIF($2, ''),
+			reviewed_by = NULLIF($3, '')::uuid, reviewed_at = $4, signed_by = NULLIF($5, '')::uuid, signed_at = $6
+		WHERE id = $7 AND review_status = $8`
+	return execOne(ctx, db, q, rv.ReviewStatus, rv.ReviewNotes, rv.ReviewedBy, rv.ReviewedAt, rv.SignedBy, rv.SignedAt, id, from)
+}
+
+// SetFractureRisk stores a recomputed fracture risk estimate on an unsigned
+// record. A signed record, whose estimate is final, is ErrNotFound.
+func (r *ReadingRepository) SetFractureRisk(ctx context.Context, mr *models.MedicalRecord) error {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if rv, ok := r.mockReviews[mr.ID]; ok && rv.ReviewStatus == models.ReviewSigned {
+			return ErrNotFound
+		}
+		for i := range r.mockMedicalRecords {
+			if x := &r.mockMedicalRecords[i]; x.ID == mr.ID {
+				x.MajorFractureRisk, x.HipFractureRisk = mr.MajorFractureRisk, mr.HipFractureRisk
+				x.TreatmentRecommended, x.RiskModelVersion = mr.TreatmentRecommended, mr.RiskModelVersion
+				return nil
+			}
+		}
+		for i := range r.mockReadings {
+			if x := &r.mockReadings[i]; x.ID == mr.ID {
+				x.MajorFractureRisk, x.HipFractureRisk = mr.MajorFractureRisk, mr.HipFractureRisk
+				x.TreatmentRecommended, x.RiskModelVersion = mr.TreatmentRecommended, mr.RiskModelVersion
+				return nil
+			}
+		}
+		return ErrNotFound
+	}
+
+	db, ok := r.db.(*sql.DB)
+	if !ok {
+		return errors.New("unsupported db type")
+	}
+	q := `UPDATE readings SET major_fracture_risk = $1, hip_fracture_risk = $2, treatment_recommended = $3, risk_model_version = NULLIF($4, '')
+		WHERE id = $5 AND review_status <> 'signed'`
+	return execOne(ctx, db, q, mr.MajorFractureRisk, mr.HipFractureRisk, mr.TreatmentRecommended, mr.RiskModelVersion, mr.ID)
+}
+
+// mockRecords lists manual records and synced readings with their review
+// state. r.mu must be held.
+func (r *ReadingRepository) mockRecords() []models.MedicalRecord {
+	out := make([]models.MedicalRecord, 0, len(r.mockMedicalRecords)+len(r.mockReadings))
+	out = append(out, r.mockMedicalRecords...)
+	for _, rd := range r.mockReadings {
+		out = append(out, recordFromReading(rd))
+	}
+	for i := range out {
+		if rv, ok := r.mockReviews[out[i].ID]; ok {
+			out[i].Review = rv
+		} else if out[i].ReviewStatus == "" {
+			out[i].ReviewStatus = models.ReviewPending
+		}
+	}
+	return out
+}
+
+// recordFromReading is the patient history view of a synced reading.
+func recordFromReading(rd models.Reading) models.MedicalRecord {
+	mr := models.MedicalRecord{
+		ID:        rd.ID,
+		PatientID: rd.PatientID,
+		DoctorID:  rd.DoctorID,
+		Source:    models.SourceDevice,
+		TScore:    rd.TScore,
+		Diagnosis: rd.Classification,
+		ScanDate:  rd.CreatedAt,
+
+		Site:               rd.Site,
+		ZScore:             rd.ZScore,
+		ReferenceVersion:   rd.ReferenceVersion,
+		DiagnosisRationale: rd.DiagnosisRationale,
+		RuleSetVersion:     rd.RuleSetVersion,
+
+		MajorFractureRisk:    rd.MajorFractureRisk,
+		HipFractureRisk:      rd.HipFractureRisk,
+		TreatmentRecommended: rd.TreatmentRecommended,
+		RiskModelVersion:     rd.RiskModelVersion,
+	}
+	if rd.BMDResult != 0 {
+		bmd := rd.BMDResult
+		mr.BMD = &bmd
 	}
-	return records, nil
+	return mr
 }
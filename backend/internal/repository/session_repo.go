@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"edora/backend/internal/models"
+)
+
+type SessionRepo interface {
+	CreateSession(ctx context.Context, s *models.Session) (string, error)
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	ListSessions(ctx context.Context, userID string) ([]models.Session, error)
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+	Revoke(ctx context.Context, sessionID, reason string) error
+	RevokeAllForUser(ctx context.Context, userID, reason string) error
+	RevokeOthersForUser(ctx context.Context, userID, keepSessionID, reason string) error
+	CreateRefreshToken(ctx context.Context, sessionID, tokenHash string, expiresAt time.Time) error
+	ConsumeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+}
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) CreateSession(ctx context.Context, s *models.Session) (string, error) {
+	q := `INSERT INTO sessions (user_id, user_agent, ip) VALUES ($1, $2, $3) RETURNING id, created_at, last_used_at`
+	if err := r.db.QueryRowContext(ctx, q, s.UserID, s.UserAgent, s.IP).Scan(&s.ID, &s.CreatedAt, &s.LastUsedAt); err != nil {
+		return "", err
+	}
+	return s.ID, nil
+}
+
+const sessionColumns = `id, user_id, COALESCE(user_agent, ''), COALESCE(ip, ''), created_at, last_used_at, revoked_at, COALESCE(revoked_reason, '')`
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var s models.Session
+	var revokedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &revokedAt, &s.RevokedReason); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return &s, nil
+}
+
+func (r *SessionRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	s, err := scanSession(r.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return s, err
+}
+
+func (r *SessionRepository) ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	return out, rows.Err()
+}
+
+// IsRevoked reports whether the session was revoked. Unknown sessions count
+// as revoked.
+func (r *SessionRepository) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	var revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT revoked_at FROM sessions WHERE id = $1`, sessionID).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return revokedAt.Valid, nil
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, sessionID, reason string) error {
+	q := `UPDATE sessions SET revoked_at = now(), revoked_reason = $2 WHERE id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, q, sessionID, reason)
+	return err
+}
+
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID, reason string) error {
+	q := `UPDATE sessions SET revoked_at = now(), revoked_reason = $2 WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, q, userID, reason)
+	return err
+}
+
+// RevokeOthersForUser revokes every session of the user except keepSessionID.
+func (r *SessionRepository) RevokeOthersForUser(ctx context.Context, userID, keepSessionID, reason string) error {
+	q := `UPDATE sessions SET revoked_at = now(), revoked_reason = $3
+		WHERE user_id = $1 AND id::text <> $2 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, q, userID, keepSessionID, reason)
+	return err
+}
+
+func (r *SessionRepository) CreateRefreshToken(ctx context.Context, sessionID, tokenHash string, expiresAt time.Time) error {
+	q := `INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3)`
+	_, err := r.db.ExecContext(ctx, q, tokenHash, sessionID, expiresAt)
+	return err
+}
+
+// ConsumeRefreshToken marks the token as used and returns it. A token that was
+// already used is still returned (with UsedAt set) so the caller can detect
+// reuse. It returns nil when the token is unknown.
+func (r *SessionRepository) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var rt models.RefreshToken
+	q := `UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1 AND used_at IS NULL RETURNING session_id, expires_at`
+	err := r.db.QueryRowContext(ctx, q, tokenHash).Scan(&rt.SessionID, &rt.ExpiresAt)
+	if err == nil {
+		_, _ = r.db.ExecContext(ctx, `UPDATE sessions SET last_used_at = now() WHERE id = $1`, rt.SessionID)
+		return &rt, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// either unknown or already used
+	var usedAt sql.NullTime
+	q = `SELECT session_id, expires_at, used_at FROM refresh_tokens WHERE token_hash = $1`
+	if err := r.db.QueryRowContext(ctx, q, tokenHash).Scan(&rt.SessionID, &rt.ExpiresAt, &usedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if usedAt.Valid {
+		rt.UsedAt = &usedAt.Time
+	}
+	return &rt, nil
+}
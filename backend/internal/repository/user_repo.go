@@ -4,12 +4,34 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"edora/backend/internal/models"
 )
 
+// ErrUsernameTaken is returned when a username is already in use.
+var ErrUsernameTaken = errors.New("username already exists")
+
 type UserRepo interface {
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	Create(ctx context.Context, u *models.User) (string, error)
+	List(ctx context.Context) ([]models.User, error)
+	UpdateRole(ctx context.Context, id, role string) error
+	SetDisabled(ctx context.Context, id string, disabled bool) error
+	Delete(ctx context.Context, id string) error
+
+	SetTOTPSecret(ctx context.Context, id, secret string) error
+	EnableTOTP(ctx context.Context, id string) error
+	DisableTOTP(ctx context.Context, id string) error
+	AdvanceTOTPStep(ctx context.Context, id string, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, id string, hashes []string) error
+	UseRecoveryCode(ctx context.Context, id, hash string) (bool, error)
+
+	SetPassword(ctx context.Context, id, hash string, mustChange bool) error
+	CreateResetToken(ctx context.Context, userID, createdBy, tokenHash string, expiresAt time.Time) error
+	ResetTokenUser(ctx context.Context, tokenHash string) (string, error)
+	ConsumeResetToken(ctx context.Context, tokenHash string) (string, error)
 }
 
 type UserRepository struct {
@@ -20,15 +42,187 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+const userColumns = `id, username, password, role, disabled, must_change_password, created_at, COALESCE(updated_at, created_at),
+	totp_enabled, COALESCE(totp_secret, ''), totp_last_step`
+
+func scanUser(row rowScanner) (*models.User, error) {
 	var u models.User
-	q := `SELECT id, username, password, role, created_at FROM users WHERE username = $1 LIMIT 1`
-	row := r.db.QueryRowContext(ctx, q, username)
-	if err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Role, &u.CreatedAt); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
+	if err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Role, &u.Disabled, &u.MustChangePassword, &u.CreatedAt, &u.UpdatedAt,
+		&u.TOTPEnabled, &u.TOTPSecret, &u.TOTPLastStep); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
+
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	q := `SELECT ` + userColumns + ` FROM users WHERE username = $1 LIMIT 1`
+	u, err := scanUser(r.db.QueryRowContext(ctx, q, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return u, err
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	q := `SELECT ` + userColumns + ` FROM users WHERE id = $1 LIMIT 1`
+	u, err := scanUser(r.db.QueryRowContext(ctx, q, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return u, err
+}
+
+// Create inserts a user whose Password is already hashed.
+func (r *UserRepository) Create(ctx context.Context, u *models.User) (string, error) {
+	q := `INSERT INTO users (username, password, role, must_change_password) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (username) DO NOTHING
+		RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, q, u.Username, u.Password, u.Role, u.MustChangePassword).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUsernameTaken
+	}
+	if err != nil {
+		return "", err
+	}
+	return u.ID, nil
+}
+
+func (r *UserRepository) List(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, id, role string) error {
+	return execOne(ctx, r.db, `UPDATE users SET role = $2, updated_at = now() WHERE id = $1`, id, role)
+}
+
+func (r *UserRepository) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	return execOne(ctx, r.db, `UPDATE users SET disabled = $2, updated_at = now() WHERE id = $1`, id, disabled)
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	return execOne(ctx, r.db, `DELETE FROM users WHERE id = $1`, id)
+}
+
+// SetTOTPSecret stores a pending (not yet enabled) TOTP secret.
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, id, secret string) error {
+	return execOne(ctx, r.db, `UPDATE users SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = 0, updated_at = now() WHERE id = $1`, id, secret)
+}
+
+func (r *UserRepository) EnableTOTP(ctx context.Context, id string) error {
+	return execOne(ctx, r.db, `UPDATE users SET totp_enabled = TRUE, updated_at = now() WHERE id = $1 AND totp_secret IS NOT NULL`, id)
+}
+
+// DisableTOTP removes the secret and every recovery code of the user.
+func (r *UserRepository) DisableTOTP(ctx context.Context, id string) error {
+	if err := execOne(ctx, r.db, `UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0, updated_at = now() WHERE id = $1`, id); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, id)
+	return err
+}
+
+// AdvanceTOTPStep records step as the last accepted TOTP step. It returns
+// false when step is not newer than the stored one (code replay).
+func (r *UserRepository) AdvanceTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`, id, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReplaceRecoveryCodes swaps all recovery codes of the user for new hashes.
+func (r *UserRepository) ReplaceRecoveryCodes(ctx context.Context, id string, hashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, id, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode burns an unused recovery code. It returns false when the
+// code does not exist or was already used.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, id, hash string) (bool, error) {
+	q := `UPDATE user_recovery_codes SET used_at = now() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, q, id, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetPassword stores a new bcrypt hash.
+func (r *UserRepository) SetPassword(ctx context.Context, id, hash string, mustChange bool) error {
+	return execOne(ctx, r.db, `UPDATE users SET password = $2, must_change_password = $3, updated_at = now() WHERE id = $1`, id, hash, mustChange)
+}
+
+// CreateResetToken stores a password reset token and invalidates any older
+// unused token of the same user.
+func (r *UserRepository) CreateResetToken(ctx context.Context, userID, createdBy, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return err
+	}
+	q := `INSERT INTO password_reset_tokens (token_hash, user_id, created_by, expires_at) VALUES ($1, $2, NULLIF($3, '')::uuid, $4)`
+	if _, err := tx.ExecContext(ctx, q, tokenHash, userID, createdBy, expiresAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResetTokenUser returns the user id of an unused, unexpired reset token
+// without burning it, or "" when the token is not usable.
+func (r *UserRepository) ResetTokenUser(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
+	q := `SELECT user_id FROM password_reset_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()`
+	err := r.db.QueryRowContext(ctx, q, tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return userID, err
+}
+
+// ConsumeResetToken burns an unused, unexpired reset token and returns its
+// user id, or "" when the token is not usable.
+func (r *UserRepository) ConsumeResetToken(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
+	q := `UPDATE password_reset_tokens SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id`
+	err := r.db.QueryRowContext(ctx, q, tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return userID, err
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"edora/backend/internal/auth"
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+const (
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+	// totpSkew accepts codes one step (30s) before or after the server clock.
+	totpSkew = 1
+)
+
+var (
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrMFANotEnrolled    = errors.New("totp not enrolled")
+	ErrMFAAlreadyEnabled = errors.New("totp already enabled")
+)
+
+// TOTPEnrollment is returned when a user starts TOTP enrolment.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFASetupRequired reports whether u must enrol TOTP before doing anything else.
+func (s *AuthService) MFASetupRequired(u *models.User) bool {
+	return slices.Contains(s.cfg.MFARequiredRoles, u.Role) && !u.TOTPEnabled
+}
+
+// StartMFAChallenge issues the short-lived token that the client exchanges,
+// together with a TOTP or recovery code, for a real session.
+func (s *AuthService) StartMFAChallenge(u *models.User) (string, time.Time, error) {
+	return s.tokens.Issue(auth.Claims{
+		Subject:   u.ID,
+		Username:  u.Username,
+		Role:      u.Role,
+		Purpose:   auth.PurposeMFA,
+		ExpiresAt: time.Now().Add(mfaTokenTTL).Unix(),
+	})
+}
+
+// VerifyMFA completes a two-step login. Either code (TOTP) or recoveryCode
+// must be set. Wrong codes count against the login guard like bad passwords.
+func (s *AuthService) VerifyMFA(ctx context.Context, mfaToken, code, recoveryCode, userAgent, ip string) (*TokenPair, error) {
+	claims, err := s.tokens.Verify(mfaToken)
+	if err != nil || claims.Purpose != auth.PurposeMFA {
+		return nil, ErrInvalidMFAToken
+	}
+	if err := s.guard.Check(claims.Username, ip); err != nil {
+		return nil, err
+	}
+
+	u, err := s.users.GetByID(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || !u.TOTPEnabled {
+		return nil, ErrInvalidMFAToken
+	}
+	if u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	var ok bool
+	if recoveryCode != "" {
+		ok, err = s.users.UseRecoveryCode(ctx, u.ID, hashToken(normalizeRecoveryCode(recoveryCode)))
+	} else {
+		ok, err = s.checkTOTP(ctx, u, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.guard.Fail(claims.Username, ip)
+		return nil, ErrInvalidMFACode
+	}
+	s.guard.Success(claims.Username)
+
+	return s.StartSession(ctx, u, userAgent, ip)
+}
+
+// EnrollTOTP generates a new pending secret for the user. It only becomes
+// active after ActivateTOTP confirms a code from the authenticator app.
+func (s *AuthService) EnrollTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, repository.ErrNotFound
+	}
+	if u.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret := auth.NewTOTPSecret()
+	if err := s.users.SetTOTPSecret(ctx, u.ID, secret); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: auth.TOTPURI(s.cfg.TOTPIssuer, u.Username, secret)}, nil
+}
+
+// ActivateTOTP confirms enrolment with a first code, enables TOTP and returns
+// fresh recovery codes plus a new token pair for the current session (the
+// old access token still says TOTP is off).
+func (s *AuthService) ActivateTOTP(ctx context.Context, userID, sessionID, code string) ([]string, *TokenPair, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u == nil {
+		return nil, nil, repository.ErrNotFound
+	}
+	if u.TOTPEnabled {
+		return nil, nil, ErrMFAAlreadyEnabled
+	}
+	if u.TOTPSecret == "" {
+		return nil, nil, ErrMFANotEnrolled
+	}
+
+	ok, err := s.checkTOTP(ctx, u, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrInvalidMFACode
+	}
+	if err := s.users.EnableTOTP(ctx, u.ID); err != nil {
+		return nil, nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(ctx, u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.TOTPEnabled = true
+	if sessionID, err = s.sessionOrNew(ctx, u, sessionID); err != nil {
+		return nil, nil, err
+	}
+	pair, err := s.issuePair(ctx, u, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return codes, pair, nil
+}
+
+// RegenerateRecoveryCodes invalidates all old recovery codes after checking
+// a current TOTP code.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, repository.ErrNotFound
+	}
+	if !u.TOTPEnabled {
+		return nil, ErrMFANotEnrolled
+	}
+	ok, err := s.checkTOTP(ctx, u, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	return s.replaceRecoveryCodes(ctx, u.ID)
+}
+
+// ResetTOTP removes TOTP from an account (admin, e.g. lost phone) and
+// revokes its sessions so the user has to log in and enrol again.
+func (s *AuthService) ResetTOTP(ctx context.Context, userID string) error {
+	if err := s.users.DisableTOTP(ctx, userID); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAllForUser(ctx, userID, "totp reset")
+}
+
+// checkTOTP validates code and burns its time step so it cannot be replayed.
+func (s *AuthService) checkTOTP(ctx context.Context, u *models.User, code string) (bool, error) {
+	step, ok := auth.ValidateTOTP(u.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+	return s.users.AdvanceTOTPStep(ctx, u.ID, step)
+}
+
+func (s *AuthService) replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	if err := s.users.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns a code like "k7q2m-x9trw" (50 bits of entropy).
+func newRecoveryCode() string {
+	b := make([]byte, 7)
+	_, _ = rand.Read(b)
+	c := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return c[:5] + "-" + c[5:]
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
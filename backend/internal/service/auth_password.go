@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"edora/backend/internal/repository"
+)
+
+var (
+	ErrPasswordReused    = errors.New("new password must differ from the current one")
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	ErrWrongPassword     = errors.New("current password is incorrect")
+)
+
+// ChangePassword lets a logged-in user set a new password. It clears the
+// must-change flag, revokes the user's other sessions and returns a new token
+// pair for the current session.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, sessionID, current, next string) (*TokenPair, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, repository.ErrNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(current)); err != nil {
+		return nil, ErrWrongPassword
+	}
+	if current == next {
+		return nil, ErrPasswordReused
+	}
+	if err := s.cfg.PasswordPolicy.Validate(next, u.Username); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.SetPassword(ctx, u.ID, string(hash), false); err != nil {
+		return nil, err
+	}
+	if sessionID, err = s.sessionOrNew(ctx, u, sessionID); err != nil {
+		return nil, err
+	}
+	if err := s.sessions.RevokeOthersForUser(ctx, u.ID, sessionID, "password changed"); err != nil {
+		return nil, err
+	}
+
+	u.MustChangePassword = false
+	return s.issuePair(ctx, u, sessionID)
+}
+
+// IssuePasswordReset creates a one-time reset token for userID on behalf of
+// an admin. Older unused tokens of the user stop working.
+func (s *AuthService) IssuePasswordReset(ctx context.Context, adminID, userID string) (string, time.Time, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if u == nil {
+		return "", time.Time{}, repository.ErrNotFound
+	}
+
+	token := newRefreshToken()
+	exp := time.Now().Add(s.cfg.ResetTokenTTL)
+	if err := s.users.CreateResetToken(ctx, u.ID, adminID, hashToken(token), exp); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, exp.UTC(), nil
+}
+
+// ResetPassword sets a new password using a reset token. Every session of the
+// user is revoked and any login lockout is lifted.
+func (s *AuthService) ResetPassword(ctx context.Context, token, next string) error {
+	hash := hashToken(token)
+	userID, err := s.users.ResetTokenUser(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return ErrInvalidResetToken
+	}
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrInvalidResetToken
+	}
+	// validate before burning the token so a weak password can be retried
+	if err := s.cfg.PasswordPolicy.Validate(next, u.Username); err != nil {
+		return err
+	}
+
+	if id, err := s.users.ConsumeResetToken(ctx, hash); err != nil {
+		return err
+	} else if id == "" {
+		return ErrInvalidResetToken
+	}
+
+	pwHash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.users.SetPassword(ctx, u.ID, string(pwHash), false); err != nil {
+		return err
+	}
+	s.guard.Unlock(u.Username)
+	return s.sessions.RevokeAllForUser(ctx, u.ID, "password reset")
+}
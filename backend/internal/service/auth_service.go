@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"edora/backend/internal/auth"
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrSessionRevoked      = errors.New("session revoked")
+	ErrAccountDisabled     = errors.New("account disabled")
+)
+
+// TokenPair is returned on login and refresh.
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Role             string    `json:"role"`
+}
+
+// dummyHash is compared against when the username does not exist so that
+// unknown users and wrong passwords take the same bcrypt time.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("edora-dummy-password"), bcrypt.DefaultCost)
+
+// AuthConfig holds the tunables of AuthService.
+type AuthConfig struct {
+	RefreshTTL time.Duration
+	// MFARequiredRoles must enrol TOTP before using any other endpoint.
+	MFARequiredRoles []string
+	// TOTPIssuer is the account issuer shown in authenticator apps.
+	TOTPIssuer string
+	// PasswordPolicy applies to password changes and resets.
+	PasswordPolicy PasswordPolicy
+	// ResetTokenTTL is the lifetime of admin-issued password reset tokens.
+	ResetTokenTTL time.Duration
+}
+
+type AuthService struct {
+	users    repository.UserRepo
+	sessions repository.SessionRepo
+	tokens   *auth.TokenManager
+	guard    *LoginGuard
+	cfg      AuthConfig
+}
+
+func NewAuthService(users repository.UserRepo, sessions repository.SessionRepo, tokens *auth.TokenManager, guard *LoginGuard, cfg AuthConfig) *AuthService {
+	if cfg.RefreshTTL <= 0 {
+		cfg.RefreshTTL = 30 * 24 * time.Hour
+	}
+	if cfg.TOTPIssuer == "" {
+		cfg.TOTPIssuer = "Edora"
+	}
+	if cfg.ResetTokenTTL <= 0 {
+		cfg.ResetTokenTTL = 24 * time.Hour
+	}
+	return &AuthService{users: users, sessions: sessions, tokens: tokens, guard: guard, cfg: cfg}
+}
+
+// Authenticate checks username and password and returns the user. Failed
+// attempts are counted per username and per client IP; while either is
+// backing off it returns a *LockedError without checking the password.
+func (s *AuthService) Authenticate(ctx context.Context, username, password, ip string) (*models.User, error) {
+	if err := s.guard.Check(username, ip); err != nil {
+		return nil, err
+	}
+
+	u, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	hash := dummyHash
+	if u != nil {
+		hash = []byte(u.Password)
+	}
+	// always run bcrypt, even for unknown users
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || u == nil {
+		s.guard.Fail(username, ip)
+		return nil, ErrInvalidCredentials
+	}
+	s.guard.Success(username)
+
+	if u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return u, nil
+}
+
+// StartSession opens a new session (token family) for u and issues the
+// first access/refresh token pair.
+func (s *AuthService) StartSession(ctx context.Context, u *models.User, userAgent, ip string) (*TokenPair, error) {
+	sess := &models.Session{UserID: u.ID, UserAgent: userAgent, IP: ip}
+	if _, err := s.sessions.CreateSession(ctx, sess); err != nil {
+		return nil, err
+	}
+	return s.issuePair(ctx, u, sess.ID)
+}
+
+// sessionOrNew returns sessionID, or opens a new session family for u when
+// the access token carries no session, e.g. one issued before sessions.
+func (s *AuthService) sessionOrNew(ctx context.Context, u *models.User, sessionID string) (string, error) {
+	if sessionID != "" {
+		return sessionID, nil
+	}
+	sess := &models.Session{UserID: u.ID}
+	if _, err := s.sessions.CreateSession(ctx, sess); err != nil {
+		return "", err
+	}
+	return sess.ID, nil
+}
+
+// Refresh exchanges a refresh token for a new pair. The presented token is
+// single-use; presenting it a second time revokes the whole session.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	rt, err := s.sessions.ConsumeRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if rt == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if rt.UsedAt != nil {
+		// token dipakai dua kali -> kemungkinan dicuri, matikan seluruh family
+		_ = s.sessions.Revoke(ctx, rt.SessionID, "refresh token reuse")
+		return nil, ErrRefreshTokenReused
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	u, err := s.sessionOwner(ctx, rt.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issuePair(ctx, u, rt.SessionID)
+}
+
+// Logout revokes the session the refresh token belongs to.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	rt, err := s.sessions.ConsumeRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if rt == nil {
+		return ErrInvalidRefreshToken
+	}
+	return s.sessions.Revoke(ctx, rt.SessionID, "logout")
+}
+
+// Unlock clears the login lockout of a user.
+func (s *AuthService) Unlock(ctx context.Context, userID string) error {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return repository.ErrNotFound
+	}
+	s.guard.Unlock(u.Username)
+	return nil
+}
+
+// UnlockIP clears the login lockout of a client IP.
+func (s *AuthService) UnlockIP(ip string) {
+	s.guard.UnlockIP(ip)
+}
+
+// RevokeSession revokes a single session, e.g. for a lost or stolen device.
+func (s *AuthService) RevokeSession(ctx context.Context, sessionID, reason string) error {
+	return s.sessions.Revoke(ctx, sessionID, reason)
+}
+
+// RevokeUserSessions revokes every session of a user.
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID, reason string) error {
+	return s.sessions.RevokeAllForUser(ctx, userID, reason)
+}
+
+func (s *AuthService) ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	return s.sessions.ListSessions(ctx, userID)
+}
+
+// VerifyAccessToken validates an access token and checks that its session
+// has not been revoked.
+func (s *AuthService) VerifyAccessToken(ctx context.Context, token string) (*models.User, error) {
+	claims, err := s.tokens.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, auth.ErrInvalidToken
+	}
+	if claims.SessionID != "" {
+		revoked, err := s.sessions.IsRevoked(ctx, claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrSessionRevoked
+		}
+	}
+	return &models.User{
+		ID:                 claims.Subject,
+		Username:           claims.Username,
+		Role:               claims.Role,
+		TOTPEnabled:        claims.MFA,
+		MustChangePassword: claims.PasswordChange,
+	}, nil
+}
+
+// SessionFromAccessToken returns the session id carried by a valid token.
+func (s *AuthService) SessionFromAccessToken(token string) (string, error) {
+	claims, err := s.tokens.Verify(token)
+	if err != nil {
+		return "", err
+	}
+	if claims.Purpose != "" {
+		return "", auth.ErrInvalidToken
+	}
+	return claims.SessionID, nil
+}
+
+// sessionOwner loads the user of a live session so refreshed tokens pick up
+// role changes.
+func (s *AuthService) sessionOwner(ctx context.Context, sessionID string) (*models.User, error) {
+	sess, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil || sess.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	u, err := s.users.GetByID(ctx, sess.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return u, nil
+}
+
+func (s *AuthService) issuePair(ctx context.Context, u *models.User, sessionID string) (*TokenPair, error) {
+	access, exp, err := s.tokens.Issue(auth.Claims{
+		Subject:        u.ID,
+		Username:       u.Username,
+		Role:           u.Role,
+		SessionID:      sessionID,
+		MFA:            u.TOTPEnabled,
+		PasswordChange: u.MustChangePassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := newRefreshToken()
+	refreshExp := time.Now().Add(s.cfg.RefreshTTL)
+	if err := s.sessions.CreateRefreshToken(ctx, sessionID, hashToken(refresh), refreshExp); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		ExpiresAt:        exp.UTC(),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExp.UTC(),
+		Role:             u.Role,
+	}, nil
+}
+
+func newRefreshToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
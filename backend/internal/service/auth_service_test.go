@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"edora/backend/internal/auth"
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+// fakeSessions keeps sessions and refresh tokens in memory; the embedded
+// interface panics on anything else.
+type fakeSessions struct {
+	repository.SessionRepo
+	sessions map[string]*models.Session
+	tokens   map[string]*models.RefreshToken
+}
+
+func newFakeSessions() *fakeSessions {
+	return &fakeSessions{sessions: map[string]*models.Session{}, tokens: map[string]*models.RefreshToken{}}
+}
+
+func (f *fakeSessions) CreateSession(_ context.Context, s *models.Session) (string, error) {
+	s.ID = "s" + strconv.Itoa(len(f.sessions)+1)
+	f.sessions[s.ID] = s
+	return s.ID, nil
+}
+
+func (f *fakeSessions) GetSession(_ context.Context, id string) (*models.Session, error) {
+	return f.sessions[id], nil
+}
+
+func (f *fakeSessions) Revoke(_ context.Context, id, reason string) error {
+	now := time.Now()
+	f.sessions[id].RevokedAt = &now
+	return nil
+}
+
+func (f *fakeSessions) CreateRefreshToken(_ context.Context, sessionID, hash string, expiresAt time.Time) error {
+	f.tokens[hash] = &models.RefreshToken{SessionID: sessionID, ExpiresAt: expiresAt}
+	return nil
+}
+
+// ConsumeRefreshToken marks the token used and returns it as it was before.
+func (f *fakeSessions) ConsumeRefreshToken(_ context.Context, hash string) (*models.RefreshToken, error) {
+	rt, ok := f.tokens[hash]
+	if !ok {
+		return nil, nil
+	}
+	before := *rt
+	now := time.Now()
+	rt.UsedAt = &now
+	return &before, nil
+}
+
+type fakeUsers struct {
+	repository.UserRepo
+	byID map[string]*models.User
+}
+
+func (f fakeUsers) GetByID(_ context.Context, id string) (*models.User, error) {
+	return f.byID[id], nil
+}
+
+func TestRefresh(t *testing.T) {
+	tokens, err := auth.NewTokenManager(auth.Config{
+		SigningKey: auth.Key{ID: "k1", Algorithm: auth.AlgHS256, Secret: []byte(strings.Repeat("k", 32))},
+	})
+	if err != nil {
+		t.Fatalf("NewTokenManager: %v", err)
+	}
+	user := &models.User{ID: "u1", Username: "dr", Role: models.RoleDoctor}
+	disabled := &models.User{ID: "u2", Username: "gone", Role: models.RoleDoctor, Disabled: true}
+
+	tests := []struct {
+		name string
+		// run refreshes as the scenario requires and returns the last error
+		run     func(s *AuthService, sessions *fakeSessions) error
+		wantErr error
+		// wantRevoked lists the sessions that must end up revoked
+		wantRevoked []string
+	}{
+		{
+			name: "rotates the token",
+			run: func(s *AuthService, _ *fakeSessions) error {
+				p := start(t, s, user)
+				next, err := s.Refresh(context.Background(), p.RefreshToken)
+				if err == nil && next.RefreshToken == p.RefreshToken {
+					t.Error("refresh token was not rotated")
+				}
+				return err
+			},
+		},
+		{
+			name: "reuse revokes the family",
+			run: func(s *AuthService, _ *fakeSessions) error {
+				p := start(t, s, user)
+				if _, err := s.Refresh(context.Background(), p.RefreshToken); err != nil {
+					t.Fatalf("first refresh: %v", err)
+				}
+				_, err := s.Refresh(context.Background(), p.RefreshToken)
+				return err
+			},
+			wantErr:     ErrRefreshTokenReused,
+			wantRevoked: []string{"s1"},
+		},
+		{
+			name: "rotated token dies with the family",
+			run: func(s *AuthService, _ *fakeSessions) error {
+				p := start(t, s, user)
+				next, err := s.Refresh(context.Background(), p.RefreshToken)
+				if err != nil {
+					t.Fatalf("first refresh: %v", err)
+				}
+				s.Refresh(context.Background(), p.RefreshToken) // stolen copy replayed
+				_, err = s.Refresh(context.Background(), next.RefreshToken)
+				return err
+			},
+			wantErr:     ErrSessionRevoked,
+			wantRevoked: []string{"s1"},
+		},
+		{
+			name: "other sessions survive",
+			run: func(s *AuthService, _ *fakeSessions) error {
+				p := start(t, s, user)
+				other := start(t, s, user)
+				s.Refresh(context.Background(), p.RefreshToken)
+				s.Refresh(context.Background(), p.RefreshToken)
+				_, err := s.Refresh(context.Background(), other.RefreshToken)
+				return err
+			},
+			wantRevoked: []string{"s1"},
+		},
+		{
+			name: "unknown token",
+			run: func(s *AuthService, _ *fakeSessions) error {
+				_, err := s.Refresh(context.Background(), "not-issued")
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "expired token",
+			run: func(s *AuthService, sessions *fakeSessions) error {
+				p := start(t, s, user)
+				sessions.tokens[hashToken(p.RefreshToken)].ExpiresAt = time.Now().Add(-time.Second)
+				_, err := s.Refresh(context.Background(), p.RefreshToken)
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "disabled account",
+			run: func(s *AuthService, _ *fakeSessions) error {
+				p := start(t, s, disabled)
+				_, err := s.Refresh(context.Background(), p.RefreshToken)
+				return err
+			},
+			wantErr: ErrAccountDisabled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			users := fakeUsers{byID: map[string]*models.User{user.ID: user, disabled.ID: disabled}}
+			s := NewAuthService(users, sessions, tokens, nil, AuthConfig{})
+
+			if err := tt.run(s, sessions); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Refresh error = %v, want %v", err, tt.wantErr)
+			}
+			var revoked []string
+			for id, sess := range sessions.sessions {
+				if sess.RevokedAt != nil {
+					revoked = append(revoked, id)
+				}
+			}
+			if strings.Join(revoked, ",") != strings.Join(tt.wantRevoked, ",") {
+				t.Errorf("revoked sessions = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func start(t *testing.T, s *AuthService, u *models.User) *TokenPair {
+	t.Helper()
+	p, err := s.StartSession(context.Background(), u, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	return p
+}
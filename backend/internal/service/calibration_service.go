@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+var (
+	ErrInvalidCalibration = errors.New("invalid calibration")
+	ErrInvalidPolicy      = errors.New("invalid calibration policy")
+	ErrCalibrationExpired = errors.New("device calibration expired")
+)
+
+type CalibrationService struct {
+	repo       repository.CalibrationRepo
+	deviceRepo repository.DeviceRepo
+	// defaults apply to facilities without their own policy
+	defaults models.CalibrationPolicy
+	// tolerance is the largest relative deviation of a measured phantom
+	// value from its reference that still passes (0.02 = 2%).
+	tolerance float64
+}
+
+func NewCalibrationService(cr repository.CalibrationRepo, dr repository.DeviceRepo, defaults models.CalibrationPolicy, tolerance float64) *CalibrationService {
+	return &CalibrationService{repo: cr, deviceRepo: dr, defaults: defaults, tolerance: tolerance}
+}
+
+// CalibrationStatus is where a device stands against its facility policy.
+type CalibrationStatus struct {
+	DueAt       time.Time
+	Overdue     bool
+	Enforcement string
+}
+
+// Record logs a calibration run for the device. When passed is nil the
+// result is derived from the phantom values and the configured tolerance.
+func (s *CalibrationService) Record(ctx context.Context, cal *models.DeviceCalibration, passed *bool) error {
+	cal.Technician = strings.TrimSpace(cal.Technician)
+	if cal.Technician == "" {
+		return fmt.Errorf("%w: technician required", ErrInvalidCalibration)
+	}
+	if len(cal.PhantomReference) == 0 {
+		return fmt.Errorf("%w: phantom_reference required", ErrInvalidCalibration)
+	}
+	if len(cal.MeasuredValues) != len(cal.PhantomReference) {
+		return fmt.Errorf("%w: measured_values must have one value per phantom_reference", ErrInvalidCalibration)
+	}
+	now := time.Now().UTC()
+	if cal.PerformedAt.IsZero() {
+		cal.PerformedAt = now
+	}
+	if cal.PerformedAt.After(now.Add(5 * time.Minute)) {
+		return fmt.Errorf("%w: performed_at is in the future", ErrInvalidCalibration)
+	}
+
+	dev, err := s.deviceRepo.GetByID(ctx, cal.DeviceID)
+	if err != nil {
+		return err
+	}
+	if dev == nil || dev.Status == models.DeviceDecommissioned {
+		return repository.ErrNotFound
+	}
+
+	if passed != nil {
+		cal.Passed = *passed
+	} else {
+		cal.Passed = s.withinTolerance(cal.PhantomReference, cal.MeasuredValues)
+	}
+	return s.repo.Create(ctx, cal)
+}
+
+func (s *CalibrationService) withinTolerance(ref, measured []float64) bool {
+	for i, r := range ref {
+		if r == 0 {
+			if measured[i] != 0 {
+				return false
+			}
+			continue
+		}
+		if math.Abs(measured[i]-r)/math.Abs(r) > s.tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the calibration history of a device, or nil when the device
+// does not exist.
+func (s *CalibrationService) List(ctx context.Context, deviceID string) ([]models.DeviceCalibration, error) {
+	dev, err := s.deviceRepo.GetByID(ctx, deviceID)
+	if err != nil || dev == nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, dev.ID)
+}
+
+// Policy returns the effective policy of a facility, falling back to the
+// defaults.
+func (s *CalibrationService) Policy(ctx context.Context, facility string) (models.CalibrationPolicy, error) {
+	p, err := s.repo.GetPolicy(ctx, facility)
+	if err != nil {
+		return models.CalibrationPolicy{}, err
+	}
+	if p == nil {
+		def := s.defaults
+		def.Facility = facility
+		return def, nil
+	}
+	return *p, nil
+}
+
+func (s *CalibrationService) SetPolicy(ctx context.Context, p models.CalibrationPolicy) error {
+	p.Facility = strings.TrimSpace(p.Facility)
+	if p.Facility == "" {
+		return fmt.Errorf("%w: facility required", ErrInvalidPolicy)
+	}
+	if p.IntervalDays <= 0 {
+		return fmt.Errorf("%w: interval_days must be positive", ErrInvalidPolicy)
+	}
+	switch p.Enforcement {
+	case models.CalibrationEnforceOff, models.CalibrationEnforceFlag, models.CalibrationEnforceReject:
+	default:
+		return fmt.Errorf("%w: enforcement must be off, flag or reject", ErrInvalidPolicy)
+	}
+	return s.repo.UpsertPolicy(ctx, p)
+}
+
+// Status computes when the device is due for calibration under its
+// facility policy. A device that never passed a calibration gets one
+// interval for its first: from registration, or from its baseline when it
+// predates calibration tracking.
+func (s *CalibrationService) Status(ctx context.Context, dev *models.Device) (CalibrationStatus, error) {
+	p, err := s.Policy(ctx, dev.Facility)
+	if err != nil {
+		return CalibrationStatus{}, err
+	}
+	return calibrationStatus(dev, p, time.Now()), nil
+}
+
+func calibrationStatus(dev *models.Device, p models.CalibrationPolicy, now time.Time) CalibrationStatus {
+	from := dev.CreatedAt
+	switch {
+	case dev.LastCalibratedAt != nil:
+		from = *dev.LastCalibratedAt
+	case dev.CalibrationBaselineAt != nil && dev.CalibrationBaselineAt.After(from):
+		from = *dev.CalibrationBaselineAt
+	}
+	due := from.AddDate(0, 0, p.IntervalDays)
+	return CalibrationStatus{DueAt: due.UTC(), Overdue: !now.Before(due), Enforcement: p.Enforcement}
+}
+
+// annotate fills CalibrationDueAt on each device.
+func (s *CalibrationService) annotate(ctx context.Context, devs ...*models.Device) error {
+	policies, err := s.repo.ListPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, dev := range devs {
+		p, ok := policies[dev.Facility]
+		if !ok {
+			p = s.defaults
+		}
+		due := calibrationStatus(dev, p, now).DueAt
+		dev.CalibrationDueAt = &due
+	}
+	return nil
+}
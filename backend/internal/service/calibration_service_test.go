@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"edora/backend/internal/models"
+)
+
+func TestCalibrationStatus(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time {
+		t := now.AddDate(0, 0, days)
+		return &t
+	}
+	policy := models.CalibrationPolicy{IntervalDays: 30, Enforcement: models.CalibrationEnforceReject}
+
+	tests := []struct {
+		name        string
+		dev         models.Device
+		wantDue     time.Time
+		wantOverdue bool
+	}{
+		{
+			name:    "calibrated within interval",
+			dev:     models.Device{CreatedAt: *at(-400), LastCalibratedAt: at(-10)},
+			wantDue: *at(20),
+		},
+		{
+			name:        "calibration expired",
+			dev:         models.Device{CreatedAt: *at(-400), LastCalibratedAt: at(-31)},
+			wantDue:     *at(-1),
+			wantOverdue: true,
+		},
+		{
+			name:        "due exactly now",
+			dev:         models.Device{CreatedAt: *at(-400), LastCalibratedAt: at(-30)},
+			wantDue:     now,
+			wantOverdue: true,
+		},
+		{
+			name:    "newly registered gets one interval",
+			dev:     models.Device{CreatedAt: now},
+			wantDue: *at(30),
+		},
+		{
+			name:        "never calibrated since registration",
+			dev:         models.Device{CreatedAt: *at(-45)},
+			wantDue:     *at(-15),
+			wantOverdue: true,
+		},
+		{
+			name:    "registered before tracking counts from the baseline",
+			dev:     models.Device{CreatedAt: *at(-400), CalibrationBaselineAt: at(-5)},
+			wantDue: *at(25),
+		},
+		{
+			name:    "last calibration wins over the baseline",
+			dev:     models.Device{CreatedAt: *at(-400), CalibrationBaselineAt: at(-5), LastCalibratedAt: at(-20)},
+			wantDue: *at(10),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := calibrationStatus(&tt.dev, policy, now)
+			if !st.DueAt.Equal(tt.wantDue) || st.Overdue != tt.wantOverdue {
+				t.Errorf("calibrationStatus = due %s overdue %v, want due %s overdue %v", st.DueAt, st.Overdue, tt.wantDue, tt.wantOverdue)
+			}
+			if st.Enforcement != policy.Enforcement {
+				t.Errorf("Enforcement = %q, want %q", st.Enforcement, policy.Enforcement)
+			}
+		})
+	}
+}
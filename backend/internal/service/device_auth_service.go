@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"edora/backend/internal/auth"
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+var (
+	ErrDeviceUnauthorized = errors.New("device authentication failed")
+	ErrStaleTimestamp     = errors.New("request timestamp outside allowed window")
+	ErrNonceReused        = errors.New("nonce already used")
+)
+
+// SignedRequest is what a device sends to prove it holds its secret.
+type SignedRequest struct {
+	Serial    string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Method    string
+	Path      string
+	Body      []byte
+}
+
+// DeviceAuthService verifies HMAC-signed device requests and issues device
+// credentials.
+type DeviceAuthService struct {
+	repo repository.DeviceRepo
+	// window is how far a request timestamp may be from the server clock.
+	// Nonces are remembered for twice this long.
+	window time.Duration
+}
+
+func NewDeviceAuthService(dr repository.DeviceRepo, window time.Duration) *DeviceAuthService {
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	return &DeviceAuthService{repo: dr, window: window}
+}
+
+// Authenticate verifies a signed request and returns the device that sent it.
+func (s *DeviceAuthService) Authenticate(ctx context.Context, r SignedRequest) (*models.Device, error) {
+	if r.Serial == "" || r.Timestamp == "" || r.Nonce == "" || r.Signature == "" {
+		return nil, ErrDeviceUnauthorized
+	}
+	if len(r.Nonce) > 64 {
+		return nil, ErrDeviceUnauthorized
+	}
+
+	ts, err := strconv.ParseInt(r.Timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrDeviceUnauthorized
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > s.window || d < -s.window {
+		return nil, ErrStaleTimestamp
+	}
+
+	dev, err := s.repo.GetBySerial(ctx, r.Serial)
+	if err != nil {
+		return nil, err
+	}
+	// unknown device and device without credentials look the same to the caller
+	if dev == nil || dev.Secret == "" {
+		return nil, ErrDeviceUnauthorized
+	}
+	if !auth.VerifyDeviceSignature(dev.Secret, r.Signature, r.Timestamp, r.Nonce, r.Method, r.Path, r.Body) {
+		return nil, ErrDeviceUnauthorized
+	}
+
+	// only checked after the signature so strangers cannot burn nonces
+	fresh, err := s.repo.UseNonce(ctx, dev.ID, r.Nonce, 2*s.window)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrNonceReused
+	}
+	return dev, nil
+}
+
+// RotateSecret issues a new secret for the device. The secret is only
+// returned here; provisioning has to copy it onto the scanner.
+func (s *DeviceAuthService) RotateSecret(ctx context.Context, serial string) (string, error) {
+	dev, err := s.repo.GetBySerial(ctx, serial)
+	if err != nil {
+		return "", err
+	}
+	if dev == nil || dev.Status == models.DeviceDecommissioned {
+		return "", repository.ErrNotFound
+	}
+	secret := auth.NewDeviceSecret()
+	if err := s.repo.SetSecret(ctx, dev.ID, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
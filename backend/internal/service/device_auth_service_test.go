@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"edora/backend/internal/auth"
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+// fakeDevices serves devices by serial and remembers used nonces; the
+// embedded interface panics on anything else.
+type fakeDevices struct {
+	repository.DeviceRepo
+	bySerial map[string]*models.Device
+	nonces   map[string]bool
+}
+
+func (f *fakeDevices) GetBySerial(_ context.Context, serial string) (*models.Device, error) {
+	return f.bySerial[serial], nil
+}
+
+func (f *fakeDevices) UseNonce(_ context.Context, deviceID, nonce string, _ time.Duration) (bool, error) {
+	k := deviceID + "/" + nonce
+	if f.nonces[k] {
+		return false, nil
+	}
+	f.nonces[k] = true
+	return true, nil
+}
+
+func TestDeviceAuthenticate(t *testing.T) {
+	const secret = "s3cret"
+	body := []byte(`{"patient_id":"p1"}`)
+	signed := func(serial, nonce string, at time.Time) SignedRequest {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		return SignedRequest{
+			Serial: serial, Timestamp: ts, Nonce: nonce, Method: "POST", Path: "/api/v1/sync/reading", Body: body,
+			Signature: auth.DeviceSignature(secret, ts, nonce, "POST", "/api/v1/sync/reading", body),
+		}
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		req     func() SignedRequest
+		wantErr error
+	}{
+		{name: "valid", req: func() SignedRequest { return signed("SN-1", "n-valid", now) }},
+		{name: "clock skew within window", req: func() SignedRequest { return signed("SN-1", "n-skew", now.Add(-4*time.Minute)) }},
+		{name: "stale timestamp", req: func() SignedRequest { return signed("SN-1", "n-stale", now.Add(-6*time.Minute)) }, wantErr: ErrStaleTimestamp},
+		{name: "future timestamp", req: func() SignedRequest { return signed("SN-1", "n-future", now.Add(6*time.Minute)) }, wantErr: ErrStaleTimestamp},
+		{name: "unknown device", req: func() SignedRequest { return signed("SN-9", "n-unknown", now) }, wantErr: ErrDeviceUnauthorized},
+		{name: "device without secret", req: func() SignedRequest { return signed("SN-2", "n-nosecret", now) }, wantErr: ErrDeviceUnauthorized},
+		{
+			name: "tampered body",
+			req: func() SignedRequest {
+				r := signed("SN-1", "n-tamper", now)
+				r.Body = []byte(`{"patient_id":"p2"}`)
+				return r
+			},
+			wantErr: ErrDeviceUnauthorized,
+		},
+		{name: "missing nonce", req: func() SignedRequest { return signed("SN-1", "", now) }, wantErr: ErrDeviceUnauthorized},
+		{
+			name: "non-numeric timestamp",
+			req: func() SignedRequest {
+				r := signed("SN-1", "n-ts", now)
+				r.Timestamp = "yesterday"
+				return r
+			},
+			wantErr: ErrDeviceUnauthorized,
+		},
+		{name: "oversized nonce", req: func() SignedRequest { return signed("SN-1", string(make([]byte, 65)), now) }, wantErr: ErrDeviceUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeDevices{
+				bySerial: map[string]*models.Device{
+					"SN-1": {ID: "d1", SerialNumber: "SN-1", Secret: secret},
+					"SN-2": {ID: "d2", SerialNumber: "SN-2"},
+				},
+				nonces: map[string]bool{},
+			}
+			svc := NewDeviceAuthService(repo, 5*time.Minute)
+			dev, err := svc.Authenticate(context.Background(), tt.req())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && dev.ID != "d1" {
+				t.Errorf("device = %s, want d1", dev.ID)
+			}
+		})
+	}
+}
+
+func TestDeviceAuthenticateRejectsNonceReuse(t *testing.T) {
+	repo := &fakeDevices{
+		bySerial: map[string]*models.Device{"SN-1": {ID: "d1", SerialNumber: "SN-1", Secret: "s3cret"}},
+		nonces:   map[string]bool{},
+	}
+	svc := NewDeviceAuthService(repo, 5*time.Minute)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req := SignedRequest{Serial: "SN-1", Timestamp: ts, Nonce: "n1", Method: "GET", Path: "/api/v1/devices/SN-1/firmware",
+		Signature: auth.DeviceSignature("s3cret", ts, "n1", "GET", "/api/v1/devices/SN-1/firmware", nil)}
+
+	if _, err := svc.Authenticate(context.Background(), req); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if _, err := svc.Authenticate(context.Background(), req); !errors.Is(err, ErrNonceReused) {
+		t.Fatalf("replayed request error = %v, want %v", err, ErrNonceReused)
+	}
+
+	// a forged request must not burn a nonce the device has yet to use
+	forged := req
+	forged.Nonce, forged.Signature = "n2", "00"
+	if _, err := svc.Authenticate(context.Background(), forged); !errors.Is(err, ErrDeviceUnauthorized) {
+		t.Fatalf("forged request error = %v, want %v", err, ErrDeviceUnauthorized)
+	}
+	if repo.nonces["d1/n2"] {
+		t.Error("forged request consumed nonce n2")
+	}
+}
@@ -2,20 +2,250 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
+	"edora/backend/internal/auth"
+	"edora/backend/internal/models"
 	"edora/backend/internal/repository"
 )
 
+var (
+	ErrSerialRequired   = errors.New("serial_number required")
+	ErrInvalidTelemetry = errors.New("invalid telemetry")
+	ErrInvalidLSC       = errors.New("lsc_bmd must be between 0 and 0.5 g/cm²")
+)
+
 type DeviceService struct {
-	repo *repository.DeviceRepository
+	repo        repository.DeviceRepo
+	calibration *CalibrationService
+}
+
+func NewDeviceService(dr repository.DeviceRepo, cal *CalibrationService) *DeviceService {
+	return &DeviceService{repo: dr, calibration: cal}
+}
+
+// DeviceDetail is a device plus its recent activity.
+type DeviceDetail struct {
+	models.Device
+	RecentReadings int       `json:"recent_readings"`
+	RecentSince    time.Time `json:"recent_since"`
+}
+
+// DeviceListQuery is a page request for ListDevices.
+type DeviceListQuery struct {
+	Statuses    []string
+	LastSeenAsc bool
+	Page        int
+	PageSize    int
+	// Window is how recently a device must have been seen to be online;
+	// devices seen within OfflineAfter are stale, older ones offline.
+	Window       time.Duration
+	OfflineAfter time.Duration
+}
+
+type DeviceList struct {
+	Items       []models.DeviceSummary `json:"items"`
+	Page        int                    `json:"page"`
+	PageSize    int                    `json:"page_size"`
+	Total       int                    `json:"total"`
+	ActiveCount int                    `json:"active_count"`
 }
 
-func NewDeviceService(dr *repository.DeviceRepository) *DeviceService {
-	return &DeviceService{repo: dr}
+// ListDevices returns one page of devices with their computed presence.
+func (s *DeviceService) ListDevices(ctx context.Context, q DeviceListQuery) (*DeviceList, error) {
+	items, total, err := s.repo.List(ctx, repository.DeviceFilter{
+		Statuses:    q.Statuses,
+		LastSeenAsc: q.LastSeenAsc,
+		Limit:       q.PageSize,
+		Offset:      (q.Page - 1) * q.PageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.repo.CountActive(ctx, q.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	devs := make([]*models.Device, len(items))
+	for i := range items {
+		items[i].Presence = presence(items[i].LastSeen, now, q.Window, q.OfflineAfter)
+		devs[i] = &items[i].Device
+	}
+	if s.calibration != nil {
+		if err := s.calibration.annotate(ctx, devs...); err != nil {
+			return nil, err
+		}
+	}
+	return &DeviceList{Items: items, Page: q.Page, PageSize: q.PageSize, Total: total, ActiveCount: active}, nil
+}
+
+func presence(lastSeen, now time.Time, window, offlineAfter time.Duration) string {
+	if lastSeen.IsZero() {
+		return models.PresenceOffline
+	}
+	switch age := now.Sub(lastSeen); {
+	case age <= window:
+		return models.PresenceOnline
+	case age <= offlineAfter:
+		return models.PresenceStale
+	}
+	return models.PresenceOffline
 }
 
 // CountActive returns number of devices active within the given duration.
 func (s *DeviceService) CountActive(ctx context.Context, since time.Duration) (int, error) {
 	return s.repo.CountActive(ctx, since)
 }
+
+// Register creates a device and issues its signing secret. The secret is
+// returned once and must be provisioned onto the scanner.
+func (s *DeviceService) Register(ctx context.Context, dev *models.Device) (string, error) {
+	dev.SerialNumber = strings.TrimSpace(dev.SerialNumber)
+	if dev.SerialNumber == "" {
+		return "", ErrSerialRequired
+	}
+	dev.Status = models.DeviceOffline
+	dev.Secret = auth.NewDeviceSecret()
+	if _, err := s.repo.Create(ctx, dev); err != nil {
+		return "", err
+	}
+	return dev.Secret, nil
+}
+
+// Get returns a device with the number of readings it uploaded in the last
+// recentWindow, or nil when it does not exist.
+func (s *DeviceService) Get(ctx context.Context, id string, recentWindow time.Duration) (*DeviceDetail, error) {
+	dev, err := s.repo.GetByID(ctx, id)
+	if err != nil || dev == nil {
+		return nil, err
+	}
+	since := time.Now().Add(-recentWindow)
+	n, err := s.repo.CountReadingsSince(ctx, dev.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	if s.calibration != nil {
+		if err := s.calibration.annotate(ctx, dev); err != nil {
+			return nil, err
+		}
+	}
+	return &DeviceDetail{Device: *dev, RecentReadings: n, RecentSince: since.UTC()}, nil
+}
+
+func (s *DeviceService) Update(ctx context.Context, dev *models.Device) error {
+	if dev.LSC != nil && (*dev.LSC <= 0 || *dev.LSC > 0.5) {
+		return ErrInvalidLSC
+	}
+	return s.repo.Update(ctx, dev)
+}
+
+// SetMaintenance puts a device into maintenance (readings are refused) or
+// takes it out again, in which case it is offline until it next checks in.
+func (s *DeviceService) SetMaintenance(ctx context.Context, id string, on bool) error {
+	status := models.DeviceOffline
+	if on {
+		status = models.DeviceMaintenance
+	}
+	return s.repo.SetStatus(ctx, id, status)
+}
+
+// Decommission retires a device permanently and revokes its credentials.
+func (s *DeviceService) Decommission(ctx context.Context, id string) error {
+	return s.repo.Decommission(ctx, id)
+}
+
+// DeviceUptime is the share of a time window a device spent online.
+type DeviceUptime struct {
+	DeviceID      string                      `json:"device_id"`
+	From          time.Time                   `json:"from"`
+	To            time.Time                   `json:"to"`
+	OnlineSeconds int64                       `json:"online_seconds"`
+	UptimePercent float64                     `json:"uptime_percent"`
+	Changes       []models.DeviceStatusChange `json:"changes"`
+}
+
+// Uptime computes how much of the last window the device was online, based
+// on device_status_history. It returns nil when the device does not exist.
+func (s *DeviceService) Uptime(ctx context.Context, id string, window time.Duration) (*DeviceUptime, error) {
+	dev, err := s.repo.GetByID(ctx, id)
+	if err != nil || dev == nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	from := to.Add(-window)
+	if dev.CreatedAt.After(from) {
+		from = dev.CreatedAt
+	}
+
+	status, changes, err := s.repo.StatusHistory(ctx, dev.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	// no history before the window (e.g. rows older than the history table)
+	if status == "" {
+		status = dev.Status
+		if len(changes) > 0 {
+			status = changes[0].FromStatus
+		}
+	}
+
+	var online time.Duration
+	cursor := from
+	for _, ch := range changes {
+		if status == models.DeviceOnline {
+			online += ch.ChangedAt.Sub(cursor)
+		}
+		status, cursor = ch.ToStatus, ch.ChangedAt
+	}
+	if status == models.DeviceOnline {
+		online += to.Sub(cursor)
+	}
+
+	up := &DeviceUptime{DeviceID: dev.ID, From: from.UTC(), To: to.UTC(), OnlineSeconds: int64(online.Seconds()), Changes: changes}
+	if total := to.Sub(from); total > 0 {
+		up.UptimePercent = math.Round(online.Seconds()/total.Seconds()*10000) / 100
+	}
+	return up, nil
+}
+
+// Heartbeat marks the device as seen and stores its telemetry sample.
+func (s *DeviceService) Heartbeat(ctx context.Context, dev *models.Device, t *models.DeviceTelemetry) error {
+	if t.BatteryLevel != nil && (*t.BatteryLevel < 0 || *t.BatteryLevel > 100) {
+		return fmt.Errorf("%w: battery_level must be between 0 and 100", ErrInvalidTelemetry)
+	}
+	if t.Temperature != nil && (*t.Temperature < -40 || *t.Temperature > 125) {
+		return fmt.Errorf("%w: temperature must be between -40 and 125", ErrInvalidTelemetry)
+	}
+	if t.FreeStorageBytes != nil && *t.FreeStorageBytes < 0 {
+		return fmt.Errorf("%w: free_storage must not be negative", ErrInvalidTelemetry)
+	}
+	if t.HardwareRevision != nil && *t.HardwareRevision <= 0 {
+		return fmt.Errorf("%w: hardware_revision must be positive", ErrInvalidTelemetry)
+	}
+	if len(t.FirmwareVersion) > 50 {
+		return fmt.Errorf("%w: firmware_version too long", ErrInvalidTelemetry)
+	}
+
+	t.RecordedAt = time.Now().UTC()
+	if err := s.repo.InsertTelemetry(ctx, dev.ID, t); err != nil {
+		return err
+	}
+	return s.repo.UpdateLastSeen(ctx, dev.ID, t.RecordedAt)
+}
+
+// Telemetry returns up to limit heartbeat samples of the device from the
+// last window, or nil when the device does not exist.
+func (s *DeviceService) Telemetry(ctx context.Context, id string, window time.Duration, limit int) ([]models.DeviceTelemetry, error) {
+	dev, err := s.repo.GetByID(ctx, id)
+	if err != nil || dev == nil {
+		return nil, err
+	}
+	return s.repo.ListTelemetry(ctx, dev.ID, time.Now().Add(-window), limit)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:embed diagnosis_rules.json
+var defaultDiagnosisRules []byte
+
+// Scores a diagnosis profile can be based on.
+const (
+	ScoreT = "t"
+	ScoreZ = "z"
+)
+
+// DiagnosisRules is a versioned, declarative rule set. Profiles are tried
+// in order; the first whose sex and age range match the patient and whose
+// score is available decides the diagnosis with its first matching rule.
+type DiagnosisRules struct {
+	Version     string             `json:"version"`
+	Description string             `json:"description"`
+	Profiles    []DiagnosisProfile `json:"profiles"`
+}
+
+// DiagnosisProfile selects patients by sex and age (inclusive, in years).
+// Empty sex and missing ages match anyone, including patients whose sex or
+// age is unknown. When the profile's score is missing, MissingScore decides
+// if set; otherwise the next profile is tried.
+type DiagnosisProfile struct {
+	Name         string          `json:"name"`
+	Sex          string          `json:"sex"`
+	MinAge       *int            `json:"min_age"`
+	MaxAge       *int            `json:"max_age"`
+	Score        string          `json:"score"`
+	MissingScore *DiagnosisRule  `json:"missing_score"`
+	Rules        []DiagnosisRule `json:"rules"`
+}
+
+// DiagnosisRule matches when the score is <= ScoreLTE and < ScoreLT; a rule
+// without thresholds always matches. {score} and {age} in Rationale are
+// replaced with the patient's values.
+type DiagnosisRule struct {
+	ScoreLTE  *float64 `json:"score_lte"`
+	ScoreLT   *float64 `json:"score_lt"`
+	Diagnosis string   `json:"diagnosis"`
+	Rationale string   `json:"rationale"`
+}
+
+// DiagnosisSubject is what the rules know about a patient and a scan. Sex
+// is M, F or empty when unknown.
+type DiagnosisSubject struct {
+	Sex    string
+	Age    *int
+	TScore *float64
+	ZScore *float64
+}
+
+// Diagnosis is the outcome of DiagnosisRules.Diagnose.
+type Diagnosis struct {
+	Diagnosis      string `json:"diagnosis"`
+	Rationale      string `json:"rationale"`
+	Profile        string `json:"profile"`
+	RuleSetVersion string `json:"rule_set_version"`
+}
+
+// ErrNoDiagnosis is returned when no profile applies, e.g. the scan has
+// neither T- nor Z-score.
+var ErrNoDiagnosis = errors.New("no diagnosis rule applies")
+
+// DefaultDiagnosisRules returns the rule set built into the binary.
+func DefaultDiagnosisRules() (*DiagnosisRules, error) {
+	return ParseDiagnosisRules(defaultDiagnosisRules)
+}
+
+// LoadDiagnosisRules reads a rule set from a JSON file.
+func LoadDiagnosisRules(path string) (*DiagnosisRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiagnosisRules(data)
+}
+
+func ParseDiagnosisRules(data []byte) (*DiagnosisRules, error) {
+	var r DiagnosisRules
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("diagnosis rules: %w", err)
+	}
+	if err := r.validate(); err != nil {
+		return nil, fmt.Errorf("diagnosis rules %s: %w", r.Version, err)
+	}
+	return &r, nil
+}
+
+func (r *DiagnosisRules) validate() error {
+	if r.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(r.Profiles) == 0 {
+		return errors.New("no profiles")
+	}
+	for i, p := range r.Profiles {
+		switch {
+		case p.Name == "":
+			return fmt.Errorf("profile %d: name is required", i)
+		case p.Sex != "" && p.Sex != "M" && p.Sex != "F":
+			return fmt.Errorf("profile %s: sex must be M, F or empty", p.Name)
+		case p.Score != ScoreT && p.Score != ScoreZ:
+			return fmt.Errorf("profile %s: score must be %q or %q", p.Name, ScoreT, ScoreZ)
+		case p.MinAge != nil && p.MaxAge != nil && *p.MinAge > *p.MaxAge:
+			return fmt.Errorf("profile %s: min_age above max_age", p.Name)
+		case len(p.Rules) == 0:
+			return fmt.Errorf("profile %s: no rules", p.Name)
+		}
+		last := p.Rules[len(p.Rules)-1]
+		if last.ScoreLTE != nil || last.ScoreLT != nil {
+			return fmt.Errorf("profile %s: last rule must have no threshold", p.Name)
+		}
+		if p.MissingScore != nil && p.MissingScore.Diagnosis == "" {
+			return fmt.Errorf("profile %s: missing_score diagnosis is required", p.Name)
+		}
+		for j, rule := range p.Rules {
+			if rule.Diagnosis == "" {
+				return fmt.Errorf("profile %s rule %d: diagnosis is required", p.Name, j)
+			}
+		}
+	}
+	return nil
+}
+
+// Diagnose applies the first matching profile to the subject.
+func (r *DiagnosisRules) Diagnose(s DiagnosisSubject) (Diagnosis, error) {
+	for _, p := range r.Profiles {
+		if !p.selects(s) {
+			continue
+		}
+		score := s.TScore
+		if p.Score == ScoreZ {
+			score = s.ZScore
+		}
+		if score == nil {
+			if p.MissingScore == nil {
+				continue
+			}
+			return Diagnosis{
+				Diagnosis:      p.MissingScore.Diagnosis,
+				Rationale:      p.MissingScore.rationale(nil, s.Age),
+				Profile:        p.Name,
+				RuleSetVersion: r.Version,
+			}, nil
+		}
+		for _, rule := range p.Rules {
+			if rule.ScoreLTE != nil && *score > *rule.ScoreLTE {
+				continue
+			}
+			if rule.ScoreLT != nil && *score >= *rule.ScoreLT {
+				continue
+			}
+			return Diagnosis{
+				Diagnosis:      rule.Diagnosis,
+				Rationale:      rule.rationale(score, s.Age),
+				Profile:        p.Name,
+				RuleSetVersion: r.Version,
+			}, nil
+		}
+	}
+	return Diagnosis{}, ErrNoDiagnosis
+}
+
+func (p *DiagnosisProfile) selects(s DiagnosisSubject) bool {
+	if p.Sex != "" && p.Sex != s.Sex {
+		return false
+	}
+	if p.MinAge == nil && p.MaxAge == nil {
+		return true
+	}
+	if s.Age == nil {
+		return false
+	}
+	return (p.MinAge == nil || *s.Age >= *p.MinAge) && (p.MaxAge == nil || *s.Age <= *p.MaxAge)
+}
+
+func (rule *DiagnosisRule) rationale(score *float64, age *int) string {
+	ageText, scoreText := "unknown", "unknown"
+	if age != nil {
+		ageText = strconv.Itoa(*age)
+	}
+	if score != nil {
+		scoreText = strconv.FormatFloat(*score, 'f', 1, 64)
+	}
+	return strings.NewReplacer("{score}", scoreText, "{age}", ageText).Replace(rule.Rationale)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	rules, err := DefaultDiagnosisRules()
+	if err != nil {
+		t.Fatalf("DefaultDiagnosisRules: %v", err)
+	}
+	age := func(v int) *int { return &v }
+	score := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name          string
+		subject       DiagnosisSubject
+		wantDiagnosis string
+		wantProfile   string
+		wantErr       error
+	}{
+		{name: "WHO osteoporosis at the threshold", subject: DiagnosisSubject{Sex: "F", Age: age(65), TScore: score(-2.5)}, wantDiagnosis: "Osteoporosis", wantProfile: "who-50-plus"},
+		{name: "WHO osteopenia", subject: DiagnosisSubject{Sex: "M", Age: age(70), TScore: score(-1.8)}, wantDiagnosis: "Osteopenia", wantProfile: "who-50-plus"},
+		{name: "WHO normal at -1.0", subject: DiagnosisSubject{Sex: "F", Age: age(50), TScore: score(-1.0)}, wantDiagnosis: "Normal", wantProfile: "who-50-plus"},
+		{name: "premenopausal woman uses Z-score", subject: DiagnosisSubject{Sex: "F", Age: age(35), TScore: score(-2.8), ZScore: score(-1.2)}, wantDiagnosis: "Within expected range for age", wantProfile: "premenopausal-women"},
+		{name: "man under 50 low Z-score", subject: DiagnosisSubject{Sex: "M", Age: age(40), ZScore: score(-2.0)}, wantDiagnosis: "Below expected range for age", wantProfile: "men-under-50"},
+		{name: "man under 50 without Z-score", subject: DiagnosisSubject{Sex: "M", Age: age(40), TScore: score(-2.6)}, wantDiagnosis: "Not classifiable", wantProfile: "men-under-50"},
+		{name: "child low Z-score", subject: DiagnosisSubject{Sex: "F", Age: age(12), ZScore: score(-2.3)}, wantDiagnosis: "Low BMD for age", wantProfile: "pediatric"},
+		{name: "child with T-score only", subject: DiagnosisSubject{Sex: "M", Age: age(15), TScore: score(-3.0)}, wantDiagnosis: "Not assessable", wantProfile: "pediatric"},
+		{name: "unknown age falls back to WHO", subject: DiagnosisSubject{Sex: "F", TScore: score(-2.7)}, wantDiagnosis: "Osteoporosis", wantProfile: "who-fallback"},
+		{name: "unknown sex under 50 falls back to WHO", subject: DiagnosisSubject{Age: age(30), TScore: score(-0.5)}, wantDiagnosis: "Normal", wantProfile: "who-fallback"},
+		{name: "no score", subject: DiagnosisSubject{Sex: "F", Age: age(60)}, wantErr: ErrNoDiagnosis},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := rules.Diagnose(tt.subject)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Diagnose error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if d.Diagnosis != tt.wantDiagnosis || d.Profile != tt.wantProfile {
+				t.Errorf("Diagnose = %s (%s), want %s (%s)", d.Diagnosis, d.Profile, tt.wantDiagnosis, tt.wantProfile)
+			}
+			if d.RuleSetVersion != rules.Version {
+				t.Errorf("RuleSetVersion = %s, want %s", d.RuleSetVersion, rules.Version)
+			}
+			if d.Rationale == "" || strings.ContainsAny(d.Rationale, "{}") {
+				t.Errorf("Rationale not filled in: %q", d.Rationale)
+			}
+		})
+	}
+}
+
+func TestParseDiagnosisRulesRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{name: "not JSON", json: `{`},
+		{name: "no version", json: `{"profiles":[{"name":"p","score":"t","rules":[{"diagnosis":"x"}]}]}`},
+		{name: "no profiles", json: `{"version":"v"}`},
+		{name: "unknown score", json: `{"version":"v","profiles":[{"name":"p","score":"q","rules":[{"diagnosis":"x"}]}]}`},
+		{name: "min_age above max_age", json: `{"version":"v","profiles":[{"name":"p","score":"t","min_age":50,"max_age":20,"rules":[{"diagnosis":"x"}]}]}`},
+		{name: "last rule has a threshold", json: `{"version":"v","profiles":[{"name":"p","score":"t","rules":[{"score_lte":-2.5,"diagnosis":"x"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDiagnosisRules([]byte(tt.json)); err == nil {
+				t.Error("ParseDiagnosisRules succeeded, want error")
+			}
+		})
+	}
+}
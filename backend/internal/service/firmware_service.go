@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+var (
+	ErrInvalidFirmware  = errors.New("invalid firmware release")
+	ErrChecksumMismatch = errors.New("firmware checksum mismatch")
+	ErrFirmwareNotFound = errors.New("firmware not found")
+)
+
+var firmwareVersionRe = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+-]{0,49}$`)
+
+// FirmwareService keeps the release registry and the binaries, which are
+// stored as files under dir.
+type FirmwareService struct {
+	repo *repository.FirmwareRepository
+	dir  string
+}
+
+func NewFirmwareService(fr *repository.FirmwareRepository, dir string) (*FirmwareService, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FirmwareService{repo: fr, dir: dir}, nil
+}
+
+// Publish stores the binary read from bin and registers the release. When
+// checksum is set it must match the SHA-256 of the upload.
+func (s *FirmwareService) Publish(ctx context.Context, rel *models.FirmwareRelease, bin io.Reader, checksum string) error {
+	rel.Version = strings.TrimSpace(rel.Version)
+	if !firmwareVersionRe.MatchString(rel.Version) {
+		return fmt.Errorf("%w: version must be 1-50 letters, digits, '.', '-' or '+'", ErrInvalidFirmware)
+	}
+	if rel.RolloutPercent < 0 || rel.RolloutPercent > 100 {
+		return fmt.Errorf("%w: rollout_percent must be between 0 and 100", ErrInvalidFirmware)
+	}
+	if rel.MinHardwareRevision != nil && rel.MaxHardwareRevision != nil && *rel.MinHardwareRevision > *rel.MaxHardwareRevision {
+		return fmt.Errorf("%w: min_hardware_revision is above max_hardware_revision", ErrInvalidFirmware)
+	}
+	if existing, err := s.repo.GetByVersion(ctx, rel.Version); err != nil {
+		return err
+	} else if existing != nil {
+		return repository.ErrVersionTaken
+	}
+
+	// write to a temp file first so a failed upload never leaves a
+	// truncated binary under the release name
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), bin)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: empty firmware file", ErrInvalidFirmware)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if checksum != "" && !strings.EqualFold(checksum, sum) {
+		return fmt.Errorf("%w: got %s", ErrChecksumMismatch, sum)
+	}
+
+	rel.ChecksumSHA256 = sum
+	rel.SizeBytes = n
+	rel.FileName = rel.Version + ".bin"
+	dst := filepath.Join(s.dir, rel.FileName)
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	if err := s.repo.Create(ctx, rel); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+func (s *FirmwareService) List(ctx context.Context) ([]models.FirmwareRelease, error) {
+	return s.repo.List(ctx)
+}
+
+// SetRollout changes the share of devices (0-100%) a release is offered to.
+func (s *FirmwareService) SetRollout(ctx context.Context, version string, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("%w: rollout_percent must be between 0 and 100", ErrInvalidFirmware)
+	}
+	return s.repo.SetRollout(ctx, version, percent)
+}
+
+// Progress returns the rollout state of a release per device, or nil when
+// the version does not exist.
+func (s *FirmwareService) Progress(ctx context.Context, version string) ([]models.DeviceFirmwareUpdate, error) {
+	rel, err := s.repo.GetByVersion(ctx, version)
+	if err != nil || rel == nil {
+		return nil, err
+	}
+	return s.repo.ReleaseProgress(ctx, rel.ID)
+}
+
+// DeviceUpdates returns the firmware releases offered to a device.
+func (s *FirmwareService) DeviceUpdates(ctx context.Context, deviceID string) ([]models.DeviceFirmwareUpdate, error) {
+	return s.repo.DeviceUpdates(ctx, deviceID)
+}
+
+// Manifest picks the newest release the device is eligible for: newer than
+// what it runs, built for its hardware revision and with the device inside
+// the staged rollout. The offer is recorded for rollout tracking.
+func (s *FirmwareService) Manifest(ctx context.Context, dev *models.Device) (*models.FirmwareManifest, error) {
+	releases, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &models.FirmwareManifest{CurrentVersion: dev.FirmwareVersion}
+	var best *models.FirmwareRelease
+	for i := range releases {
+		rel := &releases[i]
+		if !s.eligible(dev, rel) || compareVersions(rel.Version, dev.FirmwareVersion) <= 0 {
+			continue
+		}
+		if best == nil || compareVersions(rel.Version, best.Version) > 0 {
+			best = rel
+		}
+	}
+	if best == nil {
+		return m, nil
+	}
+
+	if err := s.repo.RecordOffer(ctx, dev.ID, best.ID, dev.FirmwareVersion); err != nil {
+		return nil, err
+	}
+	m.UpdateAvailable = true
+	m.Version = best.Version
+	m.ChecksumSHA256 = best.ChecksumSHA256
+	m.SizeBytes = best.SizeBytes
+	m.ReleaseNotes = best.ReleaseNotes
+	return m, nil
+}
+
+// Binary returns the path of a release binary the device may download and
+// records the download.
+func (s *FirmwareService) Binary(ctx context.Context, dev *models.Device, version string) (string, *models.FirmwareRelease, error) {
+	rel, err := s.repo.GetByVersion(ctx, version)
+	if err != nil {
+		return "", nil, err
+	}
+	if rel == nil || !s.eligible(dev, rel) {
+		return "", nil, ErrFirmwareNotFound
+	}
+	if err := s.repo.RecordDownload(ctx, dev.ID, rel.ID); err != nil {
+		return "", nil, err
+	}
+	return filepath.Join(s.dir, rel.FileName), rel, nil
+}
+
+func (s *FirmwareService) eligible(dev *models.Device, rel *models.FirmwareRelease) bool {
+	return rel.Supports(dev.HardwareRevision) && rolloutBucket(dev.SerialNumber, rel.Version) < rel.RolloutPercent
+}
+
+// rolloutBucket maps a device to 0-99 per release, so raising the rollout
+// percentage only ever adds devices and each release samples a different set.
+func rolloutBucket(serial, version string) int {
+	h := fnv.New32a()
+	h.Write([]byte(serial + "/" + version))
+	return int(h.Sum32() % 100)
+}
+
+// compareVersions orders dotted versions such as "1.10.2" numerically part by
+// part; non-numeric parts compare as strings and pre-release suffixes get no
+// special treatment. An empty version is oldest.
+func compareVersions(a, b string) int {
+	pa := strings.FieldsFunc(strings.TrimPrefix(a, "v"), isVersionSep)
+	pb := strings.FieldsFunc(strings.TrimPrefix(b, "v"), isVersionSep)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		if i >= len(pa) {
+			return -1
+		}
+		if i >= len(pb) {
+			return 1
+		}
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case pa[i] != pb[i]:
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func isVersionSep(r rune) bool {
+	return r == '.' || r == '-' || r == '+'
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoginGuardConfig tunes brute-force protection on login.
+type LoginGuardConfig struct {
+	// MaxUserFailures locks a username after this many consecutive failures.
+	MaxUserFailures int
+	// MaxIPFailures locks a client IP after this many consecutive failures.
+	MaxIPFailures int
+	// BaseDelay is the backoff after the first failure; it doubles on each
+	// further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Lockout is how long a locked username or IP stays locked.
+	Lockout time.Duration
+	// Window forgets failures older than this.
+	Window time.Duration
+}
+
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		MaxUserFailures: 5,
+		MaxIPFailures:   20,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		Lockout:         15 * time.Minute,
+		Window:          time.Hour,
+	}
+}
+
+// LockedError is returned while a username or IP is backing off or locked.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+type attempt struct {
+	failures    int
+	lastFailure time.Time
+	blockedTill time.Time
+}
+
+// LoginGuard keeps per-username and per-IP failed login counters in memory.
+type LoginGuard struct {
+	cfg LoginGuardConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]*attempt
+}
+
+func NewLoginGuard(cfg LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{cfg: cfg, now: time.Now, attempts: make(map[string]*attempt)}
+}
+
+func userKey(username string) string { return "user:" + username }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// Check returns a *LockedError when either the username or the IP is
+// currently blocked.
+func (g *LoginGuard) Check(username, ip string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	var wait time.Duration
+	for _, k := range []string{userKey(username), ipKey(ip)} {
+		if a := g.live(k, now); a != nil && a.blockedTill.After(now) {
+			wait = max(wait, a.blockedTill.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail records a failed attempt for the username and the IP.
+func (g *LoginGuard) Fail(username, ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.fail(userKey(username), g.cfg.MaxUserFailures, now)
+	g.fail(ipKey(ip), g.cfg.MaxIPFailures, now)
+
+	if len(g.attempts) > 10000 {
+		g.prune(now)
+	}
+}
+
+// Success clears the username counter. The IP counter is left alone so one
+// valid account cannot be used to reset an attacker's IP budget.
+func (g *LoginGuard) Success(username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.attempts, userKey(username))
+}
+
+// Unlock clears the lockout of a username (admin action).
+func (g *LoginGuard) Unlock(username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.attempts, userKey(username))
+}
+
+// UnlockIP clears the lockout of a client IP (admin action).
+func (g *LoginGuard) UnlockIP(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.attempts, ipKey(ip))
+}
+
+func (g *LoginGuard) fail(key string, limit int, now time.Time) {
+	a := g.live(key, now)
+	if a == nil {
+		a = &attempt{}
+		g.attempts[key] = a
+	}
+	a.failures++
+	a.lastFailure = now
+
+	if limit > 0 && a.failures >= limit {
+		a.blockedTill = now.Add(g.cfg.Lockout)
+		return
+	}
+	// exponential backoff: base, 2*base, 4*base, ... capped at MaxDelay
+	delay := g.cfg.BaseDelay << min(a.failures-1, 30)
+	if delay <= 0 || delay > g.cfg.MaxDelay {
+		delay = g.cfg.MaxDelay
+	}
+	a.blockedTill = now.Add(delay)
+}
+
+// live returns the attempt record for key, dropping it when it has aged out.
+func (g *LoginGuard) live(key string, now time.Time) *attempt {
+	a, ok := g.attempts[key]
+	if !ok {
+		return nil
+	}
+	if now.Sub(a.lastFailure) > g.cfg.Window && !a.blockedTill.After(now) {
+		delete(g.attempts, key)
+		return nil
+	}
+	return a
+}
+
+func (g *LoginGuard) prune(now time.Time) {
+	for k := range g.attempts {
+		g.live(k, now)
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoginGuardBackoff(t *testing.T) {
+	cfg := LoginGuardConfig{
+		MaxUserFailures: 5,
+		MaxIPFailures:   8,
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		Lockout:         15 * time.Minute,
+		Window:          time.Hour,
+	}
+
+	tests := []struct {
+		name string
+		// failures by alice from 10.0.0.1 before Check
+		failures  int
+		wantRetry time.Duration
+	}{
+		{name: "no failures", failures: 0},
+		{name: "first failure waits the base delay", failures: 1, wantRetry: time.Second},
+		{name: "delay doubles", failures: 2, wantRetry: 2 * time.Second},
+		{name: "delay doubles again", failures: 3, wantRetry: 4 * time.Second},
+		{name: "delay is capped", failures: 4, wantRetry: 4 * time.Second},
+		{name: "user limit locks out", failures: 5, wantRetry: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1_700_000_000, 0)
+			g := NewLoginGuard(cfg)
+			g.now = func() time.Time { return now }
+			for range tt.failures {
+				g.Fail("alice", "10.0.0.1")
+			}
+
+			err := g.Check("alice", "10.0.0.1")
+			var locked *LockedError
+			if tt.wantRetry == 0 {
+				if err != nil {
+					t.Fatalf("Check = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &locked) || locked.RetryAfter != tt.wantRetry {
+				t.Fatalf("Check = %v, want retry in %s", err, tt.wantRetry)
+			}
+
+			// the block ends exactly when announced
+			now = now.Add(tt.wantRetry)
+			if err := g.Check("alice", "10.0.0.1"); err != nil {
+				t.Errorf("Check after %s = %v, want nil", tt.wantRetry, err)
+			}
+		})
+	}
+}
+
+func TestLoginGuardKeys(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cfg := DefaultLoginGuardConfig()
+	cfg.MaxIPFailures = 3
+
+	t.Run("success clears the user but not the IP", func(t *testing.T) {
+		g := NewLoginGuard(cfg)
+		g.now = func() time.Time { return now }
+		g.Fail("alice", "10.0.0.1")
+		g.Success("alice")
+		if err := g.Check("alice", "10.0.0.2"); err != nil {
+			t.Errorf("alice from another IP: %v", err)
+		}
+		if err := g.Check("bob", "10.0.0.1"); err == nil {
+			t.Error("IP backoff was cleared by a successful login")
+		}
+	})
+
+	t.Run("IP limit locks every username", func(t *testing.T) {
+		g := NewLoginGuard(cfg)
+		g.now = func() time.Time { return now }
+		for _, u := range []string{"a", "b", "c"} {
+			g.Fail(u, "10.0.0.1")
+		}
+		var locked *LockedError
+		if err := g.Check("d", "10.0.0.1"); !errors.As(err, &locked) || locked.RetryAfter != cfg.Lockout {
+			t.Errorf("Check = %v, want lockout of %s", err, cfg.Lockout)
+		}
+		g.UnlockIP("10.0.0.1")
+		if err := g.Check("d", "10.0.0.1"); err != nil {
+			t.Errorf("after UnlockIP: %v", err)
+		}
+	})
+
+	t.Run("old failures are forgotten", func(t *testing.T) {
+		g := NewLoginGuard(cfg)
+		g.now = func() time.Time { return now }
+		for range 4 {
+			g.Fail("alice", "10.0.0.9")
+		}
+		now = now.Add(cfg.Window + time.Minute)
+		g.Fail("alice", "10.0.0.9")
+		var locked *LockedError
+		if err := g.Check("alice", "10.0.0.9"); !errors.As(err, &locked) || locked.RetryAfter != cfg.BaseDelay {
+			t.Errorf("Check = %v, want retry in %s", err, cfg.BaseDelay)
+		}
+	})
+}
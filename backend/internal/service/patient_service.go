@@ -2,17 +2,30 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"edora/backend/internal/models"
 	"edora/backend/internal/repository"
 )
 
+var (
+	// ErrInvalidRiskFactors is returned for an implausible questionnaire.
+	ErrInvalidRiskFactors = errors.New("invalid risk factors")
+	// ErrHasSignedRecords is returned when deleting a patient with signed
+	// scan results; those are kept for good.
+	ErrHasSignedRecords = errors.New("patient has signed scan results and cannot be deleted")
+)
+
 type PatientService struct {
-	repo *repository.PatientRepository
+	repo     repository.PatientRepo
+	readings *ReadingService
 }
 
-func NewPatientService(pr *repository.PatientRepository) *PatientService {
-	return &PatientService{repo: pr}
+// NewPatientService creates the service. readings, when set, refreshes the
+// fracture risk of unsigned records after the questionnaire changes.
+func NewPatientService(pr repository.PatientRepo, readings *ReadingService) *PatientService {
+	return &PatientService{repo: pr, readings: readings}
 }
 
 func (s *PatientService) CreatePatient(ctx context.Context, pt *models.Patient) (string, error) {
@@ -28,5 +41,39 @@ func (s *PatientService) UpdatePatient(ctx context.Context, pt *models.Patient)
 }
 
 func (s *PatientService) DeletePatient(ctx context.Context, id string) error {
+	signed, err := s.repo.HasSignedRecords(ctx, id)
+	if err != nil {
+		return err
+	}
+	if signed {
+		return ErrHasSignedRecords
+	}
 	return s.repo.DeletePatient(ctx, id)
 }
+
+// RiskFactors returns the fracture risk questionnaire of a patient, or nil
+// when it was never filled in. An unknown patient is repository.ErrNotFound.
+func (s *PatientService) RiskFactors(ctx context.Context, patientID string) (*models.RiskFactors, error) {
+	if _, err := s.repo.GetPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRiskFactors(ctx, patientID)
+}
+
+// SaveRiskFactors replaces the fracture risk questionnaire of a patient and
+// refreshes the estimate of their unsigned records.
+func (s *PatientService) SaveRiskFactors(ctx context.Context, rf *models.RiskFactors) error {
+	if rf.BMI != nil && (*rf.BMI < 10 || *rf.BMI > 80) {
+		return fmt.Errorf("%w: bmi must be between 10 and 80", ErrInvalidRiskFactors)
+	}
+	if _, err := s.repo.GetPatient(ctx, rf.PatientID); err != nil {
+		return err
+	}
+	if err := s.repo.UpsertRiskFactors(ctx, rf); err != nil {
+		return err
+	}
+	if s.readings == nil {
+		return nil
+	}
+	return s.readings.RefreshFractureRisk(ctx, rf.PatientID)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"edora/backend/internal/repository"
+)
+
+// PresenceMonitor periodically marks devices offline once they have been
+// silent for longer than the configured window. Status changes end up in
+// device_status_history through a database trigger.
+type PresenceMonitor struct {
+	repo     repository.DeviceRepo
+	interval time.Duration
+	silence  time.Duration
+}
+
+func NewPresenceMonitor(dr repository.DeviceRepo, interval, silence time.Duration) *PresenceMonitor {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if silence <= 0 {
+		silence = 10 * time.Minute
+	}
+	return &PresenceMonitor{repo: dr, interval: interval, silence: silence}
+}
+
+// Run blocks until ctx is cancelled, checking presence every interval.
+func (m *PresenceMonitor) Run(ctx context.Context) {
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+
+	for {
+		m.sweep(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (m *PresenceMonitor) sweep(ctx context.Context) {
+	serials, err := m.repo.MarkOffline(ctx, m.silence)
+	if err != nil {
+		log.Printf("presence monitor: %v", err)
+		return
+	}
+	if len(serials) > 0 {
+		log.Printf("presence monitor: %d device offline %v", len(serials), serials)
+	}
+}
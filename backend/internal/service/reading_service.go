@@ -2,59 +2,500 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"time"
 
+	"edora/backend/internal/analysis"
+	"edora/backend/internal/fracturerisk"
 	"edora/backend/internal/models"
+	"edora/backend/internal/reference"
 	"edora/backend/internal/repository"
 )
 
 type ReadingService struct {
 	readingRepo repository.ReadingRepo
 	deviceRepo  repository.DeviceRepo
+	patientRepo repository.PatientRepo
+	calibration *CalibrationService
+	cfg         ReadingConfig
 }
 
-func NewReadingService(rr repository.ReadingRepo, dr repository.DeviceRepo) *ReadingService {
-	return &ReadingService{readingRepo: rr, deviceRepo: dr}
+// ReadingConfig holds the tunables of reading ingestion.
+type ReadingConfig struct {
+	SignalLimits SignalLimits
+	// Analysis recomputes BMD and T-score from the signal; nil keeps the
+	// values the client sent.
+	Analysis *analysis.Model
+	// Reference scores the BMD of manual records and synced readings as T-
+	// and Z-scores; ReferenceSite is the skeletal site used when a record names none.
+	Reference     *reference.Dataset
+	ReferenceSite string
+	// Diagnosis turns scores into a diagnosis for medical records and
+	// readings.
+	Diagnosis *DiagnosisRules
+	// FractureRisk estimates 10-year fracture probability for manual
+	// records and synced readings of patients with a risk questionnaire;
+	// nil disables it.
+	FractureRisk *fracturerisk.Model
+	// DefaultLSC is the least significant BMD change (g/cm²) for scanners
+	// without their own.
+	DefaultLSC float64
 }
 
-// SyncReading validates device serial, inserts reading and updates device last seen
-func (s *ReadingService) SyncReading(ctx context.Context, rd *models.Reading, deviceSerial string) (string, error) {
+func NewReadingService(rr repository.ReadingRepo, dr repository.DeviceRepo, pr repository.PatientRepo, cal *CalibrationService, cfg ReadingConfig) *ReadingService {
+	return &ReadingService{readingRepo: rr, deviceRepo: dr, patientRepo: pr, calibration: cal, cfg: cfg}
+}
+
+// ErrReferenceScore is returned when a BMD cannot be scored against the
+// reference population, e.g. the patient's sex is unknown.
+var ErrReferenceScore = errors.New("cannot score BMD against reference population")
+
+// SyncReading validates device serial, inserts reading and updates device last seen.
+// Readings from a device past its calibration due date are flagged or
+// rejected (ErrCalibrationExpired) according to the facility policy.
+// created is false when the reading is a retry of one synced before under
+// the same ClientReadingID; the original ID is returned.
+func (s *ReadingService) SyncReading(ctx context.Context, rd *models.Reading, deviceSerial string) (id string, created bool, err error) {
+	if err := s.validateReading(ctx, rd); err != nil {
+		return "", false, err
+	}
+	dev, overdue, err := s.acceptingDevice(ctx, deviceSerial)
+	if err != nil {
+		return "", false, err
+	}
+	prepareReading(rd, dev, overdue)
+
+	id, created, err = s.readingRepo.CreateReading(ctx, rd)
+	if err != nil {
+		return "", false, err
+	}
+
+	// best-effort update last seen
+	_ = s.deviceRepo.UpdateLastSeen(ctx, dev.ID, rd.CreatedAt)
+	return id, created, nil
+}
+
+// Batch item outcomes.
+const (
+	SyncCreated   = "created"
+	SyncDuplicate = "duplicate"
+	SyncRejected  = "rejected"
+)
+
+// syncChunkSize is how many readings of a batch share one transaction.
+const syncChunkSize = 100
+
+// SyncResult is the outcome of one item of a batch sync.
+type SyncResult struct {
+	Index           int    `json:"index"`
+	ClientReadingID string `json:"client_reading_id,omitempty"`
+	Status          string `json:"status"`
+	ID              string `json:"id,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	// Problems details a rejected raw signal.
+	Problems []SignalProblem `json:"problems,omitempty"`
+	// DiscrepancyFlagged is set on created readings whose client values
+	// differ from the server analysis.
+	DiscrepancyFlagged bool `json:"discrepancy_flagged,omitempty"`
+}
+
+// SyncReadings stores a backlog of readings from one device. Items that
+// are nil were already rejected by the caller and keep the result it set.
+// Every other item is validated on its own and inserted in chunks of
+// syncChunkSize per transaction. An error is returned only when the device
+// may not upload at all or the database fails as a whole.
+func (s *ReadingService) SyncReadings(ctx context.Context, rds []*models.Reading, results []SyncResult, deviceSerial string) error {
+	dev, overdue, err := s.acceptingDevice(ctx, deviceSerial)
+	if err != nil {
+		return err
+	}
+
+	var pending []int
+	for i, rd := range rds {
+		if rd == nil {
+			continue
+		}
+		results[i].ClientReadingID = rd.ClientReadingID
+		if err := s.validateReading(ctx, rd); err != nil {
+			results[i].Status, results[i].Reason = SyncRejected, err.Error()
+			var sigErr *SignalError
+			if errors.As(err, &sigErr) {
+				results[i].Problems = sigErr.Problems
+			}
+			continue
+		}
+		prepareReading(rd, dev, overdue)
+		pending = append(pending, i)
+	}
+
+	for start := 0; start < len(pending); start += syncChunkSize {
+		chunk := pending[start:min(start+syncChunkSize, len(pending))]
+		batch := make([]*models.Reading, len(chunk))
+		for j, i := range chunk {
+			batch[j] = rds[i]
+		}
+		inserted, err := s.readingRepo.CreateReadings(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for j, i := range chunk {
+			switch res := inserted[j]; {
+			case res.Err != nil:
+				results[i].Status, results[i].Reason = SyncRejected, res.Err.Error()
+			case res.Created:
+				results[i].Status, results[i].ID = SyncCreated, res.ID
+				results[i].DiscrepancyFlagged = rds[i].DiscrepancyFlagged
+			default:
+				results[i].Status, results[i].ID = SyncDuplicate, res.ID
+			}
+		}
+	}
+
+	if len(pending) > 0 {
+		// backlog readings are old; the device itself is seen now
+		_ = s.deviceRepo.UpdateLastSeen(ctx, dev.ID, time.Now().UTC())
+	}
+	return nil
+}
+
+// validateReading checks the reading, normalizes its raw signal to the
+// typed point layout, analyses it and diagnoses it. Signal defects are
+// returned as *SignalError.
+func (s *ReadingService) validateReading(ctx context.Context, rd *models.Reading) error {
+	if len(rd.ClientReadingID) > 100 {
+		return errors.New("client_reading_id too long")
+	}
+	sig, err := s.cfg.SignalLimits.ParseSignal(rd.RawSignalData)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	rd.RawSignalData = raw
+	res := s.analyze(rd, sig)
+	if err := s.assessReading(ctx, rd); err != nil {
+		return err
+	}
+	if res != nil {
+		s.compare(rd, res)
+	}
+	return nil
+}
+
+// assessReading scores and diagnoses a synced reading on the same path as
+// manual records: its BMD is scored against the reference population with
+// the patient's sex and age at the scan, so device and manual T-scores are
+// comparable. When the patient's sex is unknown the analysis T-score is
+// kept without reference_version. Readings with no T-score at all keep the
+// client's classification.
+func (s *ReadingService) assessReading(ctx context.Context, rd *models.Reading) error {
+	at := rd.CreatedAt
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	sex, age, err := s.patientFacts(ctx, rd.PatientID, at)
+	if err != nil {
+		return err
+	}
+
+	mr := &models.MedicalRecord{PatientID: rd.PatientID, ScanDate: at, TScore: rd.TScore}
+	if rd.BMDResult > 0 {
+		bmd := rd.BMDResult
+		mr.BMD = &bmd
+	}
+	if err := s.scoreBMD(mr, sex, age); err != nil {
+		if !errors.Is(err, ErrReferenceScore) {
+			return err
+		}
+		log.Printf("⚠️  reading %s tidak diskor terhadap referensi: %v", rd.ClientReadingID, err)
+	}
+	if mr.ReferenceVersion != "" {
+		rd.TScore, rd.ZScore = mr.TScore, mr.ZScore
+		rd.Site, rd.ReferenceVersion = mr.Site, mr.ReferenceVersion
+	}
+
+	if s.cfg.Diagnosis == nil || (rd.AlgorithmVersion == "" && rd.ClientTScore == nil && rd.ReferenceVersion == "") {
+		return nil
+	}
+	if err := s.diagnose(mr, sex, age); err != nil {
+		return err
+	}
+	rd.Classification = mr.Diagnosis
+	rd.DiagnosisRationale = mr.DiagnosisRationale
+	rd.RuleSetVersion = mr.RuleSetVersion
+
+	if err := s.estimateFractureRisk(ctx, mr, sex, age); err != nil {
+		return err
+	}
+	rd.MajorFractureRisk, rd.HipFractureRisk = mr.MajorFractureRisk, mr.HipFractureRisk
+	rd.TreatmentRecommended, rd.RiskModelVersion = mr.TreatmentRecommended, mr.RiskModelVersion
+	return nil
+}
+
+// checkPatient returns repository.ErrNotFound for an unknown patient.
+// Without a patient repository there is nothing to check against.
+func (s *ReadingService) checkPatient(ctx context.Context, patientID string) error {
+	if s.patientRepo == nil {
+		return nil
+	}
+	_, err := s.patientRepo.GetPatient(ctx, patientID)
+	return err
+}
+
+// patientFacts returns the sex (M, F or empty when unknown) of a patient and
+// their age at the given time, nil when the birth date is unknown. An
+// unknown or empty patient ID gives neither.
+func (s *ReadingService) patientFacts(ctx context.Context, patientID string, at time.Time) (string, *int, error) {
+	if patientID == "" || s.patientRepo == nil {
+		return "", nil, nil
+	}
+	pt, err := s.patientRepo.GetPatient(ctx, patientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	sex, _ := reference.Sex(pt.Gender)
+	if pt.BirthDate.IsZero() {
+		return sex, nil, nil
+	}
+	age := reference.Age(pt.BirthDate, at)
+	return sex, &age, nil
+}
+
+// analyze replaces the client's BMD with the server's and sets a
+// provisional T-score from the analysis model; assessReading rescores it
+// against the reference population. When the signal cannot be analysed the
+// client values are kept, AlgorithmVersion stays empty and nil is returned.
+func (s *ReadingService) analyze(rd *models.Reading, sig models.Signal) *analysis.Result {
+	m := s.cfg.Analysis
+	if m == nil {
+		return nil
+	}
+	res, err := m.Analyze(sig)
+	if err != nil {
+		if !errors.Is(err, analysis.ErrNoSignal) {
+			log.Printf("⚠️  analisis reading %s dilewati: %v", rd.ClientReadingID, err)
+		}
+		return nil
+	}
+	rd.AlgorithmVersion = res.AlgorithmVersion
+	rd.BMDResult = res.BMD
+	rd.TScore = res.TScore
+	return res
+}
+
+// compare records how far the client's values were from the server's
+// final BMD and T-score.
+func (s *ReadingService) compare(rd *models.Reading, res *analysis.Result) {
+	final := *res
+	final.TScore = rd.TScore
+	d := s.cfg.Analysis.Compare(&final, rd.ClientBMDResult, rd.ClientTScore)
+	rd.BMDDiscrepancy = d.BMD
+	rd.TScoreDiscrepancy = d.TScore
+	rd.DiscrepancyFlagged = d.Flagged
+	if d.Flagged {
+		log.Printf("⚠️  selisih analisis reading %s (%s): server BMD %.3f T %.1f, klien %s",
+			rd.ClientReadingID, res.AlgorithmVersion, rd.BMDResult, rd.TScore, clientValues(rd))
+	}
+}
+
+func clientValues(rd *models.Reading) string {
+	out := ""
+	if rd.ClientBMDResult != nil {
+		out += fmt.Sprintf("BMD %.3f ", *rd.ClientBMDResult)
+	}
+	if rd.ClientTScore != nil {
+		out += fmt.Sprintf("T %.1f", *rd.ClientTScore)
+	}
+	return out
+}
+
+// Discrepancies lists readings of the last window whose client values
+// differed from the server analysis beyond tolerance.
+func (s *ReadingService) Discrepancies(ctx context.Context, window time.Duration, limit int) ([]models.Reading, error) {
+	return s.readingRepo.ListDiscrepancies(ctx, time.Now().Add(-window), limit)
+}
+
+// acceptingDevice returns the device if it may upload readings and whether
+// its readings must be flagged as taken with an overdue calibration.
+func (s *ReadingService) acceptingDevice(ctx context.Context, deviceSerial string) (*models.Device, bool, error) {
 	if deviceSerial == "" {
-		return "", errors.New("device_serial required")
+		return nil, false, errors.New("device_serial required")
 	}
 	dev, err := s.deviceRepo.GetBySerial(ctx, deviceSerial)
 	if err != nil {
-		return "", err
+		return nil, false, err
 	}
 	if dev == nil {
-		return "", errors.New("device not registered")
+		return nil, false, errors.New("device not registered")
+	}
+	if !dev.Accepting() {
+		return nil, false, errors.New("device is " + dev.Status)
+	}
+	if s.calibration == nil {
+		return dev, false, nil
 	}
+	st, err := s.calibration.Status(ctx, dev)
+	if err != nil {
+		return nil, false, err
+	}
+	if st.Overdue {
+		switch st.Enforcement {
+		case models.CalibrationEnforceReject:
+			return nil, false, ErrCalibrationExpired
+		case models.CalibrationEnforceFlag:
+			return dev, true, nil
+		}
+	}
+	return dev, false, nil
+}
 
+func prepareReading(rd *models.Reading, dev *models.Device, calibrationOverdue bool) {
 	rd.DeviceID = dev.ID
+	rd.CalibrationOverdue = calibrationOverdue
 	if rd.CreatedAt.IsZero() {
 		rd.CreatedAt = time.Now().UTC()
 	}
+}
 
-	id, err := s.readingRepo.CreateReading(ctx, rd)
+// scoreBMD computes the T-score, and the Z-score when the reference has
+// norms for the patient's age, from the record's BMD. Records without BMD
+// keep the scores sent by the client. The norms start at age 20, so children
+// get no Z-score and the diagnosis rules report them as not assessable.
+func (s *ReadingService) scoreBMD(mr *models.MedicalRecord, sex string, age *int) error {
+	ref := s.cfg.Reference
+	if mr.BMD == nil || ref == nil {
+		return nil
+	}
+	if *mr.BMD <= 0 {
+		return fmt.Errorf("%w: bmd_result must be positive", ErrReferenceScore)
+	}
+	if sex == "" {
+		return fmt.Errorf("%w: %v", ErrReferenceScore, reference.ErrUnknownSex)
+	}
+	if mr.Site == "" {
+		mr.Site = s.cfg.ReferenceSite
+	}
+	t, err := ref.TScore(sex, mr.Site, *mr.BMD)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("%w: %v", ErrReferenceScore, err)
+	}
+	mr.TScore = t
+	mr.ZScore = nil
+	if age != nil {
+		if z, err := ref.ZScore(sex, mr.Site, *age, *mr.BMD); err == nil {
+			mr.ZScore = &z
+		}
 	}
+	mr.ReferenceVersion = ref.Version
+	return nil
+}
 
-	// best-effort update last seen
-	_ = s.deviceRepo.UpdateLastSeen(ctx, dev.ID, rd.CreatedAt)
-	return id, nil
+// diagnose sets the diagnosis, its rationale and the rule set version.
+func (s *ReadingService) diagnose(mr *models.MedicalRecord, sex string, age *int) error {
+	d, err := s.cfg.Diagnosis.Diagnose(DiagnosisSubject{Sex: sex, Age: age, TScore: &mr.TScore, ZScore: mr.ZScore})
+	if err != nil {
+		return err
+	}
+	mr.Diagnosis = d.Diagnosis
+	mr.DiagnosisRationale = d.Rationale
+	mr.RuleSetVersion = d.RuleSetVersion
+	return nil
 }
 
-// CreateMedicalRecord membuat medical record baru melalui repository
+// estimateFractureRisk adds the 10-year fracture probability when the
+// patient has a risk questionnaire. Patients the model does not cover, e.g.
+// outside its age range, get no estimate.
+func (s *ReadingService) estimateFractureRisk(ctx context.Context, mr *models.MedicalRecord, sex string, age *int) error {
+	mr.MajorFractureRisk, mr.HipFractureRisk, mr.TreatmentRecommended, mr.RiskModelVersion = nil, nil, nil, ""
+	m := s.cfg.FractureRisk
+	if m == nil || age == nil || s.patientRepo == nil {
+		return nil
+	}
+	rf, err := s.patientRepo.GetRiskFactors(ctx, mr.PatientID)
+	if err != nil || rf == nil {
+		return err
+	}
+	e, err := m.Estimate(sex, *age, mr.TScore, rf)
+	if err != nil {
+		log.Printf("⚠️  risiko fraktur pasien %s tidak dihitung: %v", mr.PatientID, err)
+		return nil
+	}
+	mr.MajorFractureRisk = &e.MajorOsteoporotic
+	mr.HipFractureRisk = &e.Hip
+	mr.TreatmentRecommended = &e.TreatmentRecommended
+	mr.RiskModelVersion = e.ModelVersion
+	return nil
+}
+
+// CreateMedicalRecord scores the record's BMD against the reference
+// population, diagnoses it with the rules for the patient's age and sex at
+// the scan date and stores it. An unknown patient is repository.ErrNotFound.
 func (s *ReadingService) CreateMedicalRecord(ctx context.Context, mr *models.MedicalRecord) (*models.MedicalRecord, error) {
-	_, err := s.readingRepo.CreateMedicalRecord(ctx, mr)
+	if err := s.checkPatient(ctx, mr.PatientID); err != nil {
+		return nil, err
+	}
+	sex, age, err := s.patientFacts(ctx, mr.PatientID, mr.ScanDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// new results always start unreviewed
+	mr.Review = models.Review{ReviewStatus: models.ReviewPending}
+	mr.ReferenceVersion = ""
+	if err := s.scoreBMD(mr, sex, age); err != nil {
+		return nil, err
+	}
+	if err := s.diagnose(mr, sex, age); err != nil {
+		return nil, err
+	}
+
+	if err := s.estimateFractureRisk(ctx, mr, sex, age); err != nil {
+		return nil, err
+	}
+
+	_, err = s.readingRepo.CreateMedicalRecord(ctx, mr)
 	if err != nil {
 		return nil, err
 	}
 	return mr, nil
 }
 
+// RefreshFractureRisk recomputes the fracture risk of a patient's unsigned
+// records from the current risk questionnaire. Signed records keep the
+// estimate the doctor signed.
+func (s *ReadingService) RefreshFractureRisk(ctx context.Context, patientID string) error {
+	records, err := s.readingRepo.GetPatientRecords(ctx, patientID)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		mr := &records[i]
+		if mr.ReviewStatus == models.ReviewSigned {
+			continue
+		}
+		sex, age, err := s.patientFacts(ctx, patientID, mr.ScanDate)
+		if err != nil {
+			return err
+		}
+		if err := s.estimateFractureRisk(ctx, mr, sex, age); err != nil {
+			return err
+		}
+		// signed in the meantime
+		if err := s.readingRepo.SetFractureRisk(ctx, mr); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetPatientRecords mengembalikan semua medical record untuk pasien
 func (s *ReadingService) GetPatientRecords(ctx context.Context, patientID string) ([]models.MedicalRecord, error) {
 	return s.readingRepo.GetPatientRecords(ctx, patientID)
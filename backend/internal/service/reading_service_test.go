@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/reference"
+	"edora/backend/internal/repository"
+)
+
+type fakePatients struct {
+	repository.PatientRepo
+	byID map[string]*models.Patient
+}
+
+func (f fakePatients) GetPatient(_ context.Context, id string) (*models.Patient, error) {
+	if p, ok := f.byID[id]; ok {
+		return p, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f fakePatients) GetRiskFactors(context.Context, string) (*models.RiskFactors, error) {
+	return nil, nil
+}
+
+type fakeRecords struct {
+	repository.ReadingRepo
+}
+
+func (fakeRecords) CreateMedicalRecord(context.Context, *models.MedicalRecord) (string, error) {
+	return "r1", nil
+}
+
+func TestCreateMedicalRecordScoring(t *testing.T) {
+	ref, err := reference.Default()
+	if err != nil {
+		t.Fatalf("reference.Default: %v", err)
+	}
+	rules, err := DefaultDiagnosisRules()
+	if err != nil {
+		t.Fatalf("DefaultDiagnosisRules: %v", err)
+	}
+	scan := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	patients := fakePatients{byID: map[string]*models.Patient{
+		"woman": {ID: "woman", Gender: "F", BirthDate: scan.AddDate(-65, 0, 0)},
+		"child": {ID: "child", Gender: "M", BirthDate: scan.AddDate(-12, 0, 0)},
+		"nosex": {ID: "nosex", BirthDate: scan.AddDate(-65, 0, 0)},
+	}}
+	s := NewReadingService(fakeRecords{}, nil, patients, nil, ReadingConfig{
+		Reference: ref, ReferenceSite: reference.SiteFemoralNeck, Diagnosis: rules,
+	})
+
+	tests := []struct {
+		name          string
+		patientID     string
+		bmd           float64
+		wantDiagnosis string
+		wantZ         bool
+		wantErr       error
+	}{
+		// young adult F femoral neck: mean 0.858, SD 0.120
+		{name: "adult scored by WHO", patientID: "woman", bmd: 0.55, wantDiagnosis: "Osteoporosis", wantZ: true},
+		{name: "child has no norms", patientID: "child", bmd: 0.55, wantDiagnosis: "Not assessable"},
+		{name: "unknown sex", patientID: "nosex", bmd: 0.55, wantErr: ErrReferenceScore},
+		{name: "non-positive BMD", patientID: "woman", bmd: 0, wantErr: ErrReferenceScore},
+		{name: "unknown patient", patientID: "nobody", bmd: 0.55, wantErr: repository.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bmd := tt.bmd
+			mr, err := s.CreateMedicalRecord(context.Background(), &models.MedicalRecord{PatientID: tt.patientID, ScanDate: scan, BMD: &bmd})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateMedicalRecord error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if mr.Diagnosis != tt.wantDiagnosis {
+				t.Errorf("Diagnosis = %q, want %q", mr.Diagnosis, tt.wantDiagnosis)
+			}
+			if (mr.ZScore != nil) != tt.wantZ {
+				t.Errorf("ZScore = %v, want set %v", mr.ZScore, tt.wantZ)
+			}
+			if mr.ReferenceVersion != ref.Version || mr.Site != reference.SiteFemoralNeck {
+				t.Errorf("scored against %q at %q", mr.ReferenceVersion, mr.Site)
+			}
+		})
+	}
+}
+
+// fakeBatch answers CreateReadings per client reading ID the way the
+// savepoint-per-item insert does: "dup" was synced before, "refused" is
+// rolled back to its savepoint, everything else is created.
+type fakeBatch struct {
+	repository.ReadingRepo
+	chunks []int
+}
+
+func (f *fakeBatch) CreateReadings(_ context.Context, rds []*models.Reading) ([]repository.ReadingInsert, error) {
+	f.chunks = append(f.chunks, len(rds))
+	out := make([]repository.ReadingInsert, len(rds))
+	for i, rd := range rds {
+		switch rd.ClientReadingID {
+		case "dup":
+			out[i] = repository.ReadingInsert{ID: "earlier"}
+		case "refused":
+			out[i] = repository.ReadingInsert{Err: errors.New("unknown patient")}
+		default:
+			out[i] = repository.ReadingInsert{ID: "id-" + rd.ClientReadingID, Created: true}
+		}
+	}
+	return out, nil
+}
+
+type seenDevices struct {
+	fakeDevices
+	seen int
+}
+
+func (f *seenDevices) UpdateLastSeen(context.Context, string, time.Time) error {
+	f.seen++
+	return nil
+}
+
+func TestSyncReadings(t *testing.T) {
+	signal := json.RawMessage(`[{"freq":1000,"impedance":500}]`)
+	reading := func(id string) *models.Reading {
+		return &models.Reading{ClientReadingID: id, RawSignalData: signal}
+	}
+	limits := SignalLimits{MaxPoints: 10, MaxFreq: 1e6, MaxImpedance: 1e4, MaxPhase: 90}
+
+	tests := []struct {
+		name       string
+		status     string
+		rds        func() []*models.Reading
+		wantStatus []string
+		wantChunks []int
+		wantSeen   int
+		wantErr    bool
+	}{
+		{
+			name:   "each item judged on its own",
+			status: models.DeviceOnline,
+			rds: func() []*models.Reading {
+				bad := reading("bad-signal")
+				bad.RawSignalData = json.RawMessage(`[{"freq":-1,"impedance":1}]`)
+				return []*models.Reading{reading("a"), nil, bad, reading("dup"), reading("refused"), reading("b")}
+			},
+			wantStatus: []string{SyncCreated, "pre-rejected", SyncRejected, SyncDuplicate, SyncRejected, SyncCreated},
+			wantChunks: []int{4},
+			wantSeen:   1,
+		},
+		{
+			name:   "inserted in chunks",
+			status: models.DeviceOnline,
+			rds: func() []*models.Reading {
+				rds := make([]*models.Reading, syncChunkSize+1)
+				for i := range rds {
+					rds[i] = reading(strconv.Itoa(i))
+				}
+				return rds
+			},
+			wantChunks: []int{syncChunkSize, 1},
+			wantSeen:   1,
+		},
+		{
+			name:       "nothing valid leaves the device unseen",
+			status:     models.DeviceOnline,
+			rds:        func() []*models.Reading { return []*models.Reading{nil} },
+			wantStatus: []string{"pre-rejected"},
+		},
+		{
+			name:    "device in maintenance uploads nothing",
+			status:  models.DeviceMaintenance,
+			rds:     func() []*models.Reading { return []*models.Reading{reading("a")} },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := &seenDevices{fakeDevices: fakeDevices{bySerial: map[string]*models.Device{
+				"SN1": {ID: "d1", SerialNumber: "SN1", Status: tt.status},
+			}}}
+			repo := &fakeBatch{}
+			s := NewReadingService(repo, devices, nil, nil, ReadingConfig{SignalLimits: limits})
+
+			rds := tt.rds()
+			results := make([]SyncResult, len(rds))
+			for i, rd := range rds {
+				if rd == nil {
+					results[i].Status = "pre-rejected"
+				}
+			}
+			err := s.SyncReadings(context.Background(), rds, results, "SN1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SyncReadings error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for i, want := range tt.wantStatus {
+				if results[i].Status != want {
+					t.Errorf("item %d status = %q (%s), want %q", i, results[i].Status, results[i].Reason, want)
+				}
+			}
+			if !slices.Equal(repo.chunks, tt.wantChunks) {
+				t.Errorf("chunks = %v, want %v", repo.chunks, tt.wantChunks)
+			}
+			if devices.seen != tt.wantSeen {
+				t.Errorf("UpdateLastSeen called %d times, want %d", devices.seen, tt.wantSeen)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+var (
+	ErrRecordSigned      = errors.New("scan result is signed and cannot be changed")
+	ErrInvalidTransition = errors.New("invalid review transition")
+	ErrNotAssigned       = errors.New("scan result is assigned to another doctor")
+	ErrReasonRequired    = errors.New("a reason is required to reject a scan result")
+)
+
+// Review actions.
+const (
+	ReviewActionReview = "review"
+	ReviewActionSign   = "sign"
+	ReviewActionReject = "reject"
+)
+
+// reviewTransitions lists the statuses each action may start from.
+var reviewTransitions = map[string][]string{
+	ReviewActionReview: {models.ReviewPending, models.ReviewReviewed},
+	ReviewActionSign:   {models.ReviewReviewed},
+	ReviewActionReject: {models.ReviewPending, models.ReviewReviewed},
+}
+
+// ReviewRecord applies a review action of user to a scan result:
+//
+//	review: pending_review or reviewed -> reviewed, notes replace the annotation
+//	sign:   reviewed -> signed; notes, when given, replace the annotation
+//	reject: pending_review or reviewed -> rejected; notes (the reason) required
+//
+// Doctors may only act on results assigned to them or unassigned; admins on
+// any. Signed results are immutable.
+func (s *ReadingService) ReviewRecord(ctx context.Context, user *models.User, id, action, notes string) (*models.MedicalRecord, error) {
+	from, ok := reviewTransitions[action]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidTransition, action)
+	}
+	mr, err := s.readingRepo.GetRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if mr.ReviewStatus == models.ReviewSigned {
+		return nil, ErrRecordSigned
+	}
+	if user.Role != models.RoleAdmin && mr.DoctorID != "" && mr.DoctorID != user.ID {
+		return nil, ErrNotAssigned
+	}
+	if !slices.Contains(from, mr.ReviewStatus) {
+		return nil, fmt.Errorf("%w: cannot %s a %s result", ErrInvalidTransition, action, mr.ReviewStatus)
+	}
+
+	now := time.Now().UTC()
+	rv := mr.Review
+	switch action {
+	case ReviewActionReview:
+		rv.ReviewStatus, rv.ReviewNotes = models.ReviewReviewed, notes
+		rv.ReviewedBy, rv.ReviewedAt = user.ID, &now
+	case ReviewActionSign:
+		rv.ReviewStatus = models.ReviewSigned
+		if notes != "" {
+			rv.ReviewNotes = notes
+		}
+		rv.SignedBy, rv.SignedAt = user.ID, &now
+	case ReviewActionReject:
+		if notes == "" {
+			return nil, ErrReasonRequired
+		}
+		rv.ReviewStatus, rv.ReviewNotes = models.ReviewRejected, notes
+		rv.ReviewedBy, rv.ReviewedAt = user.ID, &now
+	}
+
+	err = s.readingRepo.SetReview(ctx, id, mr.ReviewStatus, rv)
+	if errors.Is(err, repository.ErrNotFound) {
+		// someone else moved it on in the meantime
+		return nil, fmt.Errorf("%w: scan result changed concurrently", ErrInvalidTransition)
+	}
+	if err != nil {
+		return nil, err
+	}
+	mr.Review = rv
+	return mr, nil
+}
+
+// Worklist returns the scan results assigned to a doctor that still need
+// review or signature, oldest first.
+func (s *ReadingService) Worklist(ctx context.Context, doctorID string, limit int) ([]models.MedicalRecord, error) {
+	return s.readingRepo.Worklist(ctx, doctorID, limit)
+}
+
+// UnassignedQueue returns the scan results assigned to no doctor that still
+// need review or signature, oldest first. Any doctor may pick them up.
+func (s *ReadingService) UnassignedQueue(ctx context.Context, limit int) ([]models.MedicalRecord, error) {
+	return s.readingRepo.Worklist(ctx, "", limit)
+}
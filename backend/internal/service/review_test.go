@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+// fakeReviews holds scan results in memory; the embedded interface panics
+// on anything else.
+type fakeReviews struct {
+	repository.ReadingRepo
+	records map[string]*models.MedicalRecord
+}
+
+func (f *fakeReviews) GetRecord(_ context.Context, id string) (*models.MedicalRecord, error) {
+	mr, ok := f.records[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := *mr
+	return &cp, nil
+}
+
+func (f *fakeReviews) SetReview(_ context.Context, id, from string, rv models.Review) error {
+	mr, ok := f.records[id]
+	if !ok || mr.ReviewStatus != from {
+		return repository.ErrNotFound
+	}
+	mr.Review = rv
+	return nil
+}
+
+func TestReviewRecord(t *testing.T) {
+	doctor := &models.User{ID: "d1", Role: models.RoleDoctor}
+	other := &models.User{ID: "d2", Role: models.RoleDoctor}
+	admin := &models.User{ID: "a1", Role: models.RoleAdmin}
+
+	tests := []struct {
+		name       string
+		status     string
+		assignedTo string
+		user       *models.User
+		action     string
+		notes      string
+		wantStatus string
+		wantErr    error
+	}{
+		{name: "review pending", status: models.ReviewPending, assignedTo: "d1", user: doctor, action: ReviewActionReview, notes: "ok", wantStatus: models.ReviewReviewed},
+		{name: "review again", status: models.ReviewReviewed, assignedTo: "d1", user: doctor, action: ReviewActionReview, wantStatus: models.ReviewReviewed},
+		{name: "sign reviewed", status: models.ReviewReviewed, assignedTo: "d1", user: doctor, action: ReviewActionSign, wantStatus: models.ReviewSigned},
+		{name: "sign unreviewed", status: models.ReviewPending, assignedTo: "d1", user: doctor, action: ReviewActionSign, wantErr: ErrInvalidTransition},
+		{name: "reject with reason", status: models.ReviewPending, assignedTo: "d1", user: doctor, action: ReviewActionReject, notes: "motion artefact", wantStatus: models.ReviewRejected},
+		{name: "reject without reason", status: models.ReviewReviewed, assignedTo: "d1", user: doctor, action: ReviewActionReject, wantErr: ErrReasonRequired},
+		{name: "review rejected", status: models.ReviewRejected, assignedTo: "d1", user: doctor, action: ReviewActionReview, wantErr: ErrInvalidTransition},
+		{name: "signed is immutable", status: models.ReviewSigned, assignedTo: "d1", user: doctor, action: ReviewActionReview, wantErr: ErrRecordSigned},
+		{name: "signed is immutable for admins", status: models.ReviewSigned, assignedTo: "d1", user: admin, action: ReviewActionReject, notes: "x", wantErr: ErrRecordSigned},
+		{name: "unknown action", status: models.ReviewPending, assignedTo: "d1", user: doctor, action: "approve", wantErr: ErrInvalidTransition},
+		{name: "assigned to another doctor", status: models.ReviewPending, assignedTo: "d1", user: other, action: ReviewActionReview, wantErr: ErrNotAssigned},
+		{name: "admin overrides the assignment", status: models.ReviewReviewed, assignedTo: "d1", user: admin, action: ReviewActionSign, wantStatus: models.ReviewSigned},
+		{name: "unassigned is open to any doctor", status: models.ReviewPending, user: other, action: ReviewActionReview, wantStatus: models.ReviewReviewed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeReviews{records: map[string]*models.MedicalRecord{
+				"r1": {ID: "r1", DoctorID: tt.assignedTo, Review: models.Review{ReviewStatus: tt.status}},
+			}}
+			s := NewReadingService(repo, nil, nil, nil, ReadingConfig{})
+
+			mr, err := s.ReviewRecord(context.Background(), tt.user, "r1", tt.action, tt.notes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReviewRecord error = %v, want %v", err, tt.wantErr)
+			}
+			stored := repo.records["r1"].Review
+			if err != nil {
+				if stored.ReviewStatus != tt.status {
+					t.Errorf("stored status changed to %s on error", stored.ReviewStatus)
+				}
+				return
+			}
+			if mr.ReviewStatus != tt.wantStatus || stored.ReviewStatus != tt.wantStatus {
+				t.Errorf("status = %s, stored %s, want %s", mr.ReviewStatus, stored.ReviewStatus, tt.wantStatus)
+			}
+			switch tt.action {
+			case ReviewActionSign:
+				if stored.SignedBy != tt.user.ID || stored.SignedAt == nil {
+					t.Errorf("signed by %q at %v", stored.SignedBy, stored.SignedAt)
+				}
+			default:
+				if stored.ReviewedBy != tt.user.ID || stored.ReviewedAt == nil || stored.ReviewNotes != tt.notes {
+					t.Errorf("reviewed by %q at %v with %q", stored.ReviewedBy, stored.ReviewedAt, stored.ReviewNotes)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown record", func(t *testing.T) {
+		s := NewReadingService(&fakeReviews{}, nil, nil, nil, ReadingConfig{})
+		if _, err := s.ReviewRecord(context.Background(), doctor, "nope", ReviewActionReview, ""); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("ReviewRecord error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestWorklist(t *testing.T) {
+	repo := repository.NewReadingRepository(nil)
+	for _, mr := range []models.MedicalRecord{
+		{PatientID: "p1", DoctorID: "d1", Notes: "mine"},
+		{PatientID: "p1", DoctorID: "d2", Notes: "theirs"},
+		{PatientID: "p1", Notes: "unassigned"},
+	} {
+		mr.Review = models.Review{ReviewStatus: models.ReviewPending}
+		if _, err := repo.CreateMedicalRecord(context.Background(), &mr); err != nil {
+			t.Fatalf("CreateMedicalRecord: %v", err)
+		}
+	}
+	s := NewReadingService(repo, nil, nil, nil, ReadingConfig{})
+
+	tests := []struct {
+		name string
+		list func() ([]models.MedicalRecord, error)
+		want string
+	}{
+		{name: "worklist holds only assigned results", list: func() ([]models.MedicalRecord, error) { return s.Worklist(context.Background(), "d1", 10) }, want: "mine"},
+		{name: "unassigned queue", list: func() ([]models.MedicalRecord, error) { return s.UnassignedQueue(context.Background(), 10) }, want: "unassigned"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := tt.list()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the mock repository also holds demo records of other patients
+			var got []string
+			for _, mr := range records {
+				if mr.PatientID == "p1" {
+					got = append(got, mr.Notes)
+				}
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("got %v, want only %q", got, tt.want)
+			}
+		})
+	}
+}
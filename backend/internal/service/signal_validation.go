@@ -0,0 +1,105 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"edora/backend/internal/models"
+)
+
+// SignalLimits bounds what a raw impedance signal may contain.
+type SignalLimits struct {
+	MaxPoints    int
+	MinFreq      float64 // Hz, exclusive
+	MaxFreq      float64 // Hz
+	MaxImpedance float64 // ohm
+	MaxPhase     float64 // degrees, the range is [-MaxPhase, MaxPhase]
+}
+
+func DefaultSignalLimits() SignalLimits {
+	return SignalLimits{MaxPoints: 4096, MinFreq: 0, MaxFreq: 10e6, MaxImpedance: 1e7, MaxPhase: 180}
+}
+
+// SignalProblem is one defect of a signal. Index is the point it concerns,
+// or -1 for the signal as a whole.
+type SignalProblem struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// SignalError lists every problem found in a signal.
+type SignalError struct {
+	Problems []SignalProblem
+}
+
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("invalid raw_signal_data: %d problem(s)", len(e.Problems))
+}
+
+// ParseSignal decodes and validates raw signal data. A missing signal is
+// empty; anything else must be an array of points with strictly increasing
+// frequencies inside the limits, otherwise a *SignalError is returned.
+func (l SignalLimits) ParseSignal(raw json.RawMessage) (models.Signal, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return models.Signal{}, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, &SignalError{Problems: []SignalProblem{{Index: -1, Message: "must be an array of {freq, impedance, phase} points"}}}
+	}
+	if len(items) > l.MaxPoints {
+		return nil, &SignalError{Problems: []SignalProblem{{Index: -1, Message: fmt.Sprintf("has %d points, at most %d allowed", len(items), l.MaxPoints)}}}
+	}
+
+	var problems []SignalProblem
+	add := func(i int, field, format string, args ...any) {
+		problems = append(problems, SignalProblem{Index: i, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	sig := make(models.Signal, 0, len(items))
+	prevFreq, havePrev := 0.0, false
+	for i, item := range items {
+		var p struct {
+			Freq      *float64 `json:"freq"`
+			Impedance *float64 `json:"impedance"`
+			Phase     *float64 `json:"phase"`
+		}
+		if err := json.Unmarshal(item, &p); err != nil {
+			add(i, "", "must be an object with numeric freq, impedance and phase")
+			continue
+		}
+
+		switch {
+		case p.Freq == nil:
+			add(i, "freq", "is required")
+		case *p.Freq <= l.MinFreq || *p.Freq > l.MaxFreq:
+			add(i, "freq", "%g Hz is outside (%g, %g]", *p.Freq, l.MinFreq, l.MaxFreq)
+		case havePrev && *p.Freq <= prevFreq:
+			add(i, "freq", "%g Hz does not increase over the previous point (%g Hz)", *p.Freq, prevFreq)
+		}
+		if p.Freq != nil {
+			prevFreq, havePrev = *p.Freq, true
+		}
+
+		switch {
+		case p.Impedance == nil:
+			add(i, "impedance", "is required")
+		case *p.Impedance < 0 || *p.Impedance > l.MaxImpedance:
+			add(i, "impedance", "%g ohm is outside [0, %g]", *p.Impedance, l.MaxImpedance)
+		}
+		if p.Phase != nil && (*p.Phase < -l.MaxPhase || *p.Phase > l.MaxPhase) {
+			add(i, "phase", "%g degrees is outside [%g, %g]", *p.Phase, -l.MaxPhase, l.MaxPhase)
+		}
+
+		if p.Freq != nil && p.Impedance != nil {
+			sig = append(sig, models.SignalPoint{Freq: *p.Freq, Impedance: *p.Impedance, Phase: p.Phase})
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &SignalError{Problems: problems}
+	}
+	return sig, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	limits := SignalLimits{MaxPoints: 3, MinFreq: 0, MaxFreq: 1e6, MaxImpedance: 1e4, MaxPhase: 90}
+
+	tests := []struct {
+		name       string
+		raw        string
+		wantPoints int
+		// wantProblems lists "index/field" of every expected problem
+		wantProblems []string
+	}{
+		{name: "missing", raw: ``},
+		{name: "null", raw: `null`},
+		{name: "empty array", raw: `[]`},
+		{name: "valid", raw: `[{"freq":1000,"impedance":500,"phase":-10},{"freq":5000,"impedance":450}]`, wantPoints: 2},
+		{name: "not an array", raw: `{"freq":1000}`, wantProblems: []string{"-1/"}},
+		{name: "too many points", raw: `[{"freq":1,"impedance":1},{"freq":2,"impedance":1},{"freq":3,"impedance":1},{"freq":4,"impedance":1}]`, wantProblems: []string{"-1/"}},
+		{name: "point is not an object", raw: `[{"freq":1000,"impedance":500},"x"]`, wantProblems: []string{"1/"}},
+		{name: "missing fields", raw: `[{"phase":0}]`, wantProblems: []string{"0/freq", "0/impedance"}},
+		{name: "zero frequency", raw: `[{"freq":0,"impedance":1}]`, wantProblems: []string{"0/freq"}},
+		{name: "frequency above limit", raw: `[{"freq":2e6,"impedance":1}]`, wantProblems: []string{"0/freq"}},
+		{name: "frequency not increasing", raw: `[{"freq":1000,"impedance":1},{"freq":1000,"impedance":1}]`, wantProblems: []string{"1/freq"}},
+		{name: "negative impedance", raw: `[{"freq":1000,"impedance":-1}]`, wantProblems: []string{"0/impedance"}},
+		{name: "phase out of range", raw: `[{"freq":1000,"impedance":1,"phase":91}]`, wantProblems: []string{"0/phase"}},
+		{name: "every problem is reported", raw: `[{"freq":1000,"impedance":-1},{"freq":500,"impedance":1,"phase":-91}]`, wantProblems: []string{"0/impedance", "1/freq", "1/phase"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := limits.ParseSignal(json.RawMessage(tt.raw))
+			if len(tt.wantProblems) == 0 {
+				if err != nil {
+					t.Fatalf("ParseSignal: %v", err)
+				}
+				if len(sig) != tt.wantPoints {
+					t.Errorf("got %d points, want %d", len(sig), tt.wantPoints)
+				}
+				return
+			}
+
+			var sigErr *SignalError
+			if !errors.As(err, &sigErr) {
+				t.Fatalf("ParseSignal error = %v, want *SignalError", err)
+			}
+			var got []string
+			for _, p := range sigErr.Problems {
+				got = append(got, strconv.Itoa(p.Index)+"/"+p.Field)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.wantProblems, " ") {
+				t.Errorf("problems = %v, want %v", got, tt.wantProblems)
+			}
+		})
+	}
+}
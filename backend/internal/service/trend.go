@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"edora/backend/internal/models"
+)
+
+// Directions of a BMD change.
+const (
+	TrendLoss   = "loss"
+	TrendGain   = "gain"
+	TrendStable = "stable"
+)
+
+// TrendPoint is one scan with a BMD.
+type TrendPoint struct {
+	RecordID     string    `json:"record_id"`
+	ScanDate     time.Time `json:"scan_date"`
+	BMD          float64   `json:"bmd"`
+	TScore       float64   `json:"t_score"`
+	DeviceSerial string    `json:"device_serial,omitempty"`
+}
+
+// TrendChange compares two scans. LSC is the least significant change for
+// the pair of scanners; a change is Significant when its magnitude reaches
+// it. AnnualizedPercent is nil for scans on the same day.
+type TrendChange struct {
+	FromRecordID      string   `json:"from_record_id"`
+	ToRecordID        string   `json:"to_record_id"`
+	Days              int      `json:"days"`
+	AbsoluteChange    float64  `json:"absolute_change"`
+	PercentChange     float64  `json:"percent_change"`
+	AnnualizedPercent *float64 `json:"annualized_percent_change"`
+	LSC               float64  `json:"lsc"`
+	Significant       bool     `json:"significant"`
+	Direction         string   `json:"direction"`
+}
+
+// TrendSeries is the BMD history at one skeletal site, oldest first, with
+// the change between consecutive scans and from the first to the last.
+type TrendSeries struct {
+	Site    string        `json:"site"`
+	Points  []TrendPoint  `json:"points"`
+	Changes []TrendChange `json:"changes"`
+	Overall *TrendChange  `json:"overall,omitempty"`
+}
+
+// PatientTrend is the longitudinal BMD analysis of a patient.
+type PatientTrend struct {
+	PatientID string        `json:"patient_id"`
+	Series    []TrendSeries `json:"series"`
+}
+
+// Trend computes BMD changes between the scans of a patient, per site or
+// only for site when it is not empty. Scans without BMD and rejected scans
+// are skipped. An unknown patient is repository.ErrNotFound.
+func (s *ReadingService) Trend(ctx context.Context, patientID, site string) (*PatientTrend, error) {
+	if err := s.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	records, err := s.readingRepo.GetPatientRecords(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	bySite := map[string][]TrendPoint{}
+	for _, mr := range records {
+		// a non-positive BMD is a bad measurement and has no percent change
+		if mr.BMD == nil || *mr.BMD <= 0 || mr.ReviewStatus == models.ReviewRejected || (site != "" && mr.Site != site) {
+			continue
+		}
+		bySite[mr.Site] = append(bySite[mr.Site], TrendPoint{
+			RecordID:     mr.ID,
+			ScanDate:     mr.ScanDate,
+			BMD:          *mr.BMD,
+			TScore:       mr.TScore,
+			DeviceSerial: mr.DeviceSerial,
+		})
+	}
+
+	lsc := map[string]float64{}
+	out := &PatientTrend{PatientID: patientID, Series: []TrendSeries{}}
+	for st, points := range bySite {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].ScanDate.Before(points[j].ScanDate) })
+		series := TrendSeries{Site: st, Points: points, Changes: []TrendChange{}}
+		for i := 1; i < len(points); i++ {
+			c, err := s.trendChange(ctx, lsc, points[i-1], points[i])
+			if err != nil {
+				return nil, err
+			}
+			series.Changes = append(series.Changes, c)
+		}
+		if len(points) > 2 {
+			c, err := s.trendChange(ctx, lsc, points[0], points[len(points)-1])
+			if err != nil {
+				return nil, err
+			}
+			series.Overall = &c
+		} else if len(series.Changes) == 1 {
+			series.Overall = &series.Changes[0]
+		}
+		out.Series = append(out.Series, series)
+	}
+	sort.Slice(out.Series, func(i, j int) bool { return out.Series[i].Site < out.Series[j].Site })
+	return out, nil
+}
+
+func (s *ReadingService) trendChange(ctx context.Context, cache map[string]float64, from, to TrendPoint) (TrendChange, error) {
+	lscFrom, err := s.deviceLSC(ctx, cache, from.DeviceSerial)
+	if err != nil {
+		return TrendChange{}, err
+	}
+	lscTo, err := s.deviceLSC(ctx, cache, to.DeviceSerial)
+	if err != nil {
+		return TrendChange{}, err
+	}
+
+	c := TrendChange{
+		FromRecordID:   from.RecordID,
+		ToRecordID:     to.RecordID,
+		Days:           int(to.ScanDate.Sub(from.ScanDate).Hours() / 24),
+		AbsoluteChange: round(to.BMD-from.BMD, 3),
+		PercentChange:  round((to.BMD-from.BMD)/from.BMD*100, 2),
+		// ISCD: LSC = 2.77 × precision error (PE) = 1.96·√2·PE. Across two
+		// scanners the PEs add in quadrature, 1.96·√(PE₁²+PE₂²), which is the
+		// RMS of the two LSCs and the device LSC itself when both are equal.
+		LSC: round(math.Sqrt((lscFrom*lscFrom+lscTo*lscTo)/2), 3),
+	}
+	if c.Days > 0 {
+		annual := round(c.PercentChange/(float64(c.Days)/365.25), 2)
+		c.AnnualizedPercent = &annual
+	}
+	c.Significant = math.Abs(to.BMD-from.BMD) >= c.LSC
+	switch {
+	case !c.Significant:
+		c.Direction = TrendStable
+	case to.BMD < from.BMD:
+		c.Direction = TrendLoss
+	default:
+		c.Direction = TrendGain
+	}
+	return c, nil
+}
+
+// deviceLSC is the LSC configured for the scanner, or the default when the
+// scanner is unknown or has none.
+func (s *ReadingService) deviceLSC(ctx context.Context, cache map[string]float64, serial string) (float64, error) {
+	if v, ok := cache[serial]; ok {
+		return v, nil
+	}
+	v := s.cfg.DefaultLSC
+	if serial != "" {
+		dev, err := s.deviceRepo.GetBySerial(ctx, serial)
+		if err != nil {
+			return 0, err
+		}
+		if dev != nil && dev.LSC != nil {
+			v = *dev.LSC
+		}
+	}
+	cache[serial] = v
+	return v, nil
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
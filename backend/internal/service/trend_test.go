@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"edora/backend/internal/models"
+)
+
+func TestTrendChange(t *testing.T) {
+	lsc := func(v float64) *float64 { return &v }
+	s := NewReadingService(nil, &fakeDevices{bySerial: map[string]*models.Device{
+		"SN-A": {SerialNumber: "SN-A", LSC: lsc(0.03)},
+		"SN-B": {SerialNumber: "SN-B", LSC: lsc(0.04)},
+		"SN-C": {SerialNumber: "SN-C"},
+	}}, nil, nil, ReadingConfig{DefaultLSC: 0.05})
+
+	day0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	point := func(serial string, days int, bmd float64) TrendPoint {
+		return TrendPoint{ScanDate: day0.AddDate(0, 0, days), BMD: bmd, DeviceSerial: serial}
+	}
+
+	tests := []struct {
+		name          string
+		from, to      TrendPoint
+		wantAbs       float64
+		wantPercent   float64
+		wantAnnual    *float64
+		wantLSC       float64
+		wantDirection string
+	}{
+		{
+			name: "loss beyond the device LSC", from: point("SN-A", 0, 0.800), to: point("SN-A", 365, 0.760),
+			wantAbs: -0.04, wantPercent: -5, wantAnnual: lsc(-5), wantLSC: 0.03, wantDirection: TrendLoss,
+		},
+		{
+			name: "gain within the device LSC is stable", from: point("SN-A", 0, 0.800), to: point("SN-A", 730, 0.820),
+			wantAbs: 0.02, wantPercent: 2.5, wantAnnual: lsc(1.25), wantLSC: 0.03, wantDirection: TrendStable,
+		},
+		{
+			name: "change exactly at the LSC is significant", from: point("SN-A", 0, 0.900), to: point("SN-A", 365, 0.930),
+			wantAbs: 0.03, wantPercent: 3.33, wantAnnual: lsc(3.33), wantLSC: 0.03, wantDirection: TrendGain,
+		},
+		{
+			// RMS of 0.03 and 0.04
+			name: "two scanners", from: point("SN-A", 0, 1.000), to: point("SN-B", 365, 0.960),
+			wantAbs: -0.04, wantPercent: -4, wantAnnual: lsc(-4), wantLSC: 0.035, wantDirection: TrendLoss,
+		},
+		{
+			name: "scanner without LSC uses the default", from: point("SN-C", 0, 1.000), to: point("", 365, 0.960),
+			wantAbs: -0.04, wantPercent: -4, wantAnnual: lsc(-4), wantLSC: 0.05, wantDirection: TrendStable,
+		},
+		{
+			name: "same day has no annualized change", from: point("SN-A", 0, 1.000), to: point("SN-A", 0, 0.900),
+			wantAbs: -0.1, wantPercent: -10, wantLSC: 0.03, wantDirection: TrendLoss,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := s.trendChange(context.Background(), map[string]float64{}, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("trendChange: %v", err)
+			}
+			if c.AbsoluteChange != tt.wantAbs || c.PercentChange != tt.wantPercent || c.LSC != tt.wantLSC || c.Direction != tt.wantDirection {
+				t.Errorf("change = %+.3f (%+.2f%%), LSC %.3f, %s; want %+.3f (%+.2f%%), LSC %.3f, %s",
+					c.AbsoluteChange, c.PercentChange, c.LSC, c.Direction, tt.wantAbs, tt.wantPercent, tt.wantLSC, tt.wantDirection)
+			}
+			if (c.AnnualizedPercent == nil) != (tt.wantAnnual == nil) || (c.AnnualizedPercent != nil && *c.AnnualizedPercent != *tt.wantAnnual) {
+				t.Errorf("annualized = %v, want %v", c.AnnualizedPercent, tt.wantAnnual)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"edora/backend/internal/models"
+	"edora/backend/internal/repository"
+)
+
+var (
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrInvalidUsername = errors.New("username required")
+	ErrSelfModify      = errors.New("cannot disable or delete your own account")
+)
+
+type UserService struct {
+	users    repository.UserRepo
+	sessions repository.SessionRepo
+	policy   PasswordPolicy
+}
+
+func NewUserService(users repository.UserRepo, sessions repository.SessionRepo, policy PasswordPolicy) *UserService {
+	return &UserService{users: users, sessions: sessions, policy: policy}
+}
+
+// CreateUser hashes the password with bcrypt and stores a new user. The admin
+// chose the initial password, so the user must change it on first login.
+func (s *UserService) CreateUser(ctx context.Context, username, password, role string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, ErrInvalidUsername
+	}
+	if !models.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if err := s.policy.Validate(password, username); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	u := &models.User{Username: username, Password: string(hash), Role: role, MustChangePassword: true}
+	if _, err := s.users.Create(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *UserService) ListUsers(ctx context.Context) ([]models.User, error) {
+	return s.users.List(ctx)
+}
+
+func (s *UserService) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return s.users.GetByID(ctx, id)
+}
+
+// UpdateRole changes a user's role. Existing sessions are revoked so the new
+// role takes effect on the next login instead of after token expiry.
+func (s *UserService) UpdateRole(ctx context.Context, id, role string) error {
+	if !models.ValidRole(role) {
+		return ErrInvalidRole
+	}
+	if err := s.users.UpdateRole(ctx, id, role); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAllForUser(ctx, id, "role changed")
+}
+
+// SetDisabled enables or disables an account. Disabling also revokes every
+// session of the user.
+func (s *UserService) SetDisabled(ctx context.Context, actorID, id string, disabled bool) error {
+	if disabled && actorID == id {
+		return ErrSelfModify
+	}
+	if err := s.users.SetDisabled(ctx, id, disabled); err != nil {
+		return err
+	}
+	if disabled {
+		return s.sessions.RevokeAllForUser(ctx, id, "account disabled")
+	}
+	return nil
+}
+
+func (s *UserService) DeleteUser(ctx context.Context, actorID, id string) error {
+	if actorID == id {
+		return ErrSelfModify
+	}
+	return s.users.Delete(ctx, id)
+}
@@ -0,0 +1,14 @@
+-- Per-device credentials untuk POST /sync/reading.
+-- Secret disimpan apa adanya karena server perlu menghitung ulang HMAC.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS api_secret TEXT;
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS secret_rotated_at TIMESTAMP WITH TIME ZONE;
+
+-- Nonce yang sudah dipakai dalam jendela waktu signature (anti replay).
+CREATE TABLE IF NOT EXISTS device_nonces (
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    nonce VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (device_id, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_nonces_created_at ON device_nonces(created_at);
@@ -0,0 +1,8 @@
+-- Device lifecycle: registrasi via API, fasilitas, maintenance & decommission.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS facility VARCHAR(100);
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS decommissioned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+-- status: 'online', 'offline', 'maintenance', 'decommissioned'
+CREATE INDEX IF NOT EXISTS idx_devices_status ON devices(status);
+CREATE INDEX IF NOT EXISTS idx_readings_device_id_created_at ON readings(device_id, created_at);
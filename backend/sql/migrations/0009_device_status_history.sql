@@ -0,0 +1,27 @@
+-- Riwayat perubahan status device (online/offline/maintenance/decommissioned).
+-- Diisi oleh trigger supaya semua jalur update (sync, monitor, admin) tercatat.
+CREATE TABLE IF NOT EXISTS device_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_status_history_device_changed ON device_status_history(device_id, changed_at);
+
+CREATE OR REPLACE FUNCTION record_device_status_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO device_status_history (device_id, from_status, to_status) VALUES (NEW.id, NULL, NEW.status);
+    ELSIF NEW.status IS DISTINCT FROM OLD.status THEN
+        INSERT INTO device_status_history (device_id, from_status, to_status) VALUES (NEW.id, OLD.status, NEW.status);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_device_status_history ON devices;
+CREATE TRIGGER trg_device_status_history
+    AFTER INSERT OR UPDATE OF status ON devices
+    FOR EACH ROW EXECUTE FUNCTION record_device_status_change();
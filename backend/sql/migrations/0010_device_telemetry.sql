@@ -0,0 +1,15 @@
+-- Heartbeat device: telemetry time-series untuk grafik kesehatan alat.
+CREATE TABLE IF NOT EXISTS device_telemetry (
+    id BIGSERIAL PRIMARY KEY,
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    firmware_version VARCHAR(50),
+    battery_level REAL,        -- persen 0..100
+    temperature REAL,          -- derajat Celsius
+    free_storage_bytes BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_telemetry_device_recorded ON device_telemetry(device_id, recorded_at DESC);
+
+-- Versi firmware terakhir yang dilaporkan device
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS firmware_version VARCHAR(50);
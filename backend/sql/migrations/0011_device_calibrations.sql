@@ -0,0 +1,25 @@
+-- Kalibrasi phantom berkala untuk scanner densitas tulang.
+CREATE TABLE IF NOT EXISTS device_calibrations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    performed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    technician VARCHAR(100) NOT NULL,
+    phantom_reference JSONB NOT NULL, -- nilai referensi phantom (array angka)
+    measured_values JSONB NOT NULL,   -- nilai terukur, urutan sama dengan referensi
+    passed BOOLEAN NOT NULL,
+    notes TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_calibrations_device_performed ON device_calibrations(device_id, performed_at DESC);
+
+-- Kebijakan per fasilitas. enforcement: 'off', 'flag' (reading ditandai), 'reject' (reading ditolak).
+CREATE TABLE IF NOT EXISTS facility_calibration_policies (
+    facility VARCHAR(100) PRIMARY KEY,
+    interval_days INTEGER NOT NULL CHECK (interval_days > 0),
+    enforcement VARCHAR(10) NOT NULL DEFAULT 'flag' CHECK (enforcement IN ('off', 'flag', 'reject')),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS calibration_overdue BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,30 @@
+-- Registry firmware untuk update OTA scanner.
+CREATE TABLE IF NOT EXISTS firmware_releases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    version VARCHAR(50) UNIQUE NOT NULL,
+    checksum_sha256 CHAR(64) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,   -- nama file di FIRMWARE_DIR
+    release_notes TEXT,
+    min_hardware_revision INTEGER,     -- NULL = tanpa batas
+    max_hardware_revision INTEGER,
+    rollout_percent INTEGER NOT NULL DEFAULT 0 CHECK (rollout_percent BETWEEN 0 AND 100),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS hardware_revision INTEGER;
+
+-- Progres rollout per device: ditawarkan -> diunduh -> terpasang (dilaporkan lewat heartbeat).
+CREATE TABLE IF NOT EXISTS device_firmware_updates (
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    release_id UUID NOT NULL REFERENCES firmware_releases(id) ON DELETE CASCADE,
+    from_version VARCHAR(50),
+    offered_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    downloaded_at TIMESTAMP WITH TIME ZONE,
+    installed_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (device_id, release_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_firmware_updates_release ON device_firmware_updates(release_id);
@@ -0,0 +1,5 @@
+-- ID dari aplikasi (UUID / Idempotency-Key) agar retry sync tidak membuat scan ganda.
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS client_reading_id VARCHAR(100);
+
+-- NULL boleh berulang (reading lama tanpa ID klien)
+CREATE UNIQUE INDEX IF NOT EXISTS uq_readings_device_client_id ON readings(device_id, client_reading_id);
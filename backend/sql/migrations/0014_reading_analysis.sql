@@ -0,0 +1,9 @@
+-- Hasil analisis server: versi algoritma, nilai dari klien, dan selisihnya.
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS algorithm_version VARCHAR(50);
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS client_bmd_result FLOAT;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS client_t_score FLOAT;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS bmd_discrepancy FLOAT;      -- server - klien
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS t_score_discrepancy FLOAT;  -- server - klien
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS discrepancy_flagged BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_readings_discrepancy_flagged ON readings(created_at DESC) WHERE discrepancy_flagged;
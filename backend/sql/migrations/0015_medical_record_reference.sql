@@ -0,0 +1,5 @@
+-- Skor T/Z dihitung server dari BMD terhadap populasi referensi.
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS bmd_result REAL;
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS site VARCHAR(30);
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS z_score REAL;
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS reference_version VARCHAR(50); -- versi dataset referensi
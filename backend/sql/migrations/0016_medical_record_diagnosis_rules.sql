@@ -0,0 +1,3 @@
+-- Diagnosis dari rules engine: alasan dan versi rule set yang dipakai.
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS diagnosis_rationale TEXT;
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS rule_set_version VARCHAR(50);
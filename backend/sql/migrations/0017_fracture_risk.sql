@@ -0,0 +1,19 @@
+-- Kuesioner faktor risiko fraktur per pasien.
+CREATE TABLE IF NOT EXISTS patient_risk_factors (
+    patient_id UUID PRIMARY KEY REFERENCES patients(id) ON DELETE CASCADE,
+    prior_fracture BOOLEAN NOT NULL DEFAULT FALSE,
+    parental_hip_fracture BOOLEAN NOT NULL DEFAULT FALSE,
+    current_smoking BOOLEAN NOT NULL DEFAULT FALSE,
+    glucocorticoids BOOLEAN NOT NULL DEFAULT FALSE,       -- oral >= 3 bulan
+    rheumatoid_arthritis BOOLEAN NOT NULL DEFAULT FALSE,
+    alcohol BOOLEAN NOT NULL DEFAULT FALSE,               -- >= 3 unit/hari
+    bmi REAL CHECK (bmi > 0),
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Probabilitas fraktur 10 tahun (%) saat scan dibuat.
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS major_fracture_risk REAL;
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS hip_fracture_risk REAL;
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS treatment_recommended BOOLEAN;
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS risk_model_version VARCHAR(50);
@@ -0,0 +1,5 @@
+-- Least significant change (g/cm²) per alat, untuk membedakan perubahan BMD nyata dari noise.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS lsc_bmd REAL CHECK (lsc_bmd > 0);
+
+-- Alat yang dipakai untuk scan (kolom ada di init.sql, belum tentu di skema lama).
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS device_serial TEXT;
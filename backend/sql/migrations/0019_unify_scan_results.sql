@@ -0,0 +1,43 @@
+-- Satu tabel hasil scan: reading dari alat (source 'device') dan input manual
+-- (source 'manual', sebelumnya di medical_records) sama-sama di readings.
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'device'
+    CHECK (source IN ('device', 'manual'));
+ALTER TABLE readings ALTER COLUMN bmd_result DROP NOT NULL;      -- input manual bisa tanpa BMD
+ALTER TABLE readings ALTER COLUMN raw_signal_data DROP NOT NULL; -- input manual tanpa sinyal
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS notes TEXT;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS site VARCHAR(30);
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS z_score REAL;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS reference_version VARCHAR(50);
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS diagnosis_rationale TEXT;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS rule_set_version VARCHAR(50);
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS major_fracture_risk REAL;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS hip_fracture_risk REAL;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS treatment_recommended BOOLEAN;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS risk_model_version VARCHAR(50);
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS legacy_record_id INTEGER UNIQUE; -- medical_records.id asal
+
+CREATE INDEX IF NOT EXISTS idx_readings_patient_created ON readings(patient_id, created_at DESC);
+
+-- Pindahkan medical_records lama, lalu simpan tabelnya sebagai medical_records_legacy.
+DO $$
+BEGIN
+    IF to_regclass('medical_records') IS NULL OR to_regclass('medical_records_legacy') IS NOT NULL THEN
+        RETURN;
+    END IF;
+
+    INSERT INTO readings (source, legacy_record_id, patient_id, doctor_id, device_id,
+        bmd_result, t_score, classification, raw_signal_data, latitude, longitude, notes, created_at,
+        site, z_score, reference_version, diagnosis_rationale, rule_set_version,
+        major_fracture_risk, hip_fracture_risk, treatment_recommended, risk_model_version)
+    SELECT 'manual', m.id, m.patient_id, u.id, d.id,
+        m.bmd_result, m.t_score, m.diagnosis, to_jsonb(m.raw_signal_data), m.lat, m.long, m.notes,
+        COALESCE(m.scan_date, m.created_at, now()),
+        m.site, m.z_score, m.reference_version, m.diagnosis_rationale, m.rule_set_version,
+        m.major_fracture_risk, m.hip_fracture_risk, m.treatment_recommended, m.risk_model_version
+    FROM medical_records m
+    LEFT JOIN devices d ON d.serial_number = m.device_serial
+    LEFT JOIN users u ON u.id = m.doctor_id
+    ON CONFLICT (legacy_record_id) DO NOTHING;
+
+    ALTER TABLE medical_records RENAME TO medical_records_legacy;
+END $$;
@@ -0,0 +1,63 @@
+-- Review dan tanda tangan dokter untuk hasil scan:
+-- pending_review -> reviewed -> signed, atau rejected.
+-- Hasil lama (termasuk medical_records yang dimigrasi) diberi status legacy agar
+-- tidak membanjiri worklist; hanya hasil baru yang mulai dari pending_review.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'readings' AND column_name = 'review_status') THEN
+        ALTER TABLE readings ADD COLUMN review_status VARCHAR(20) NOT NULL DEFAULT 'legacy'
+            CHECK (review_status IN ('pending_review', 'reviewed', 'signed', 'rejected', 'legacy'));
+        ALTER TABLE readings ALTER COLUMN review_status SET DEFAULT 'pending_review';
+    END IF;
+END $$;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS review_notes TEXT;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS signed_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS signed_at TIMESTAMP WITH TIME ZONE;
+
+-- Worklist dokter: scan yang belum ditandatangani.
+CREATE INDEX IF NOT EXISTS idx_readings_review_worklist ON readings(doctor_id, created_at)
+    WHERE review_status IN ('pending_review', 'reviewed');
+
+-- Hasil yang sudah ditandatangani tidak boleh diubah atau dihapus lagi.
+-- Kolom FK ke users/devices (doctor_id, reviewed_by, signed_by, device_id)
+-- boleh berubah agar ON DELETE SET NULL saat user/alat dihapus tetap jalan.
+CREATE OR REPLACE FUNCTION readings_signed_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.review_status <> 'signed' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        RAISE EXCEPTION 'reading % is signed and cannot be deleted', OLD.id;
+    END IF;
+    IF (NEW.id, NEW.patient_id, NEW.source, NEW.client_reading_id, NEW.legacy_record_id,
+            NEW.bmd_result, NEW.t_score, NEW.classification, NEW.raw_signal_data,
+            NEW.latitude, NEW.longitude, NEW.created_at, NEW.notes, NEW.calibration_overdue,
+            NEW.algorithm_version, NEW.client_bmd_result, NEW.client_t_score,
+            NEW.bmd_discrepancy, NEW.t_score_discrepancy, NEW.discrepancy_flagged,
+            NEW.site, NEW.z_score, NEW.reference_version, NEW.diagnosis_rationale, NEW.rule_set_version,
+            NEW.major_fracture_risk, NEW.hip_fracture_risk, NEW.treatment_recommended, NEW.risk_model_version,
+            NEW.review_status, NEW.review_notes, NEW.reviewed_at, NEW.signed_at)
+        IS DISTINCT FROM
+        (OLD.id, OLD.patient_id, OLD.source, OLD.client_reading_id, OLD.legacy_record_id,
+            OLD.bmd_result, OLD.t_score, OLD.classification, OLD.raw_signal_data,
+            OLD.latitude, OLD.longitude, OLD.created_at, OLD.notes, OLD.calibration_overdue,
+            OLD.algorithm_version, OLD.client_bmd_result, OLD.client_t_score,
+            OLD.bmd_discrepancy, OLD.t_score_discrepancy, OLD.discrepancy_flagged,
+            OLD.site, OLD.z_score, OLD.reference_version, OLD.diagnosis_rationale, OLD.rule_set_version,
+            OLD.major_fracture_risk, OLD.hip_fracture_risk, OLD.treatment_recommended, OLD.risk_model_version,
+            OLD.review_status, OLD.review_notes, OLD.reviewed_at, OLD.signed_at) THEN
+        RAISE EXCEPTION 'reading % is signed and cannot be modified', OLD.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_readings_signed_immutable ON readings;
+CREATE TRIGGER trg_readings_signed_immutable BEFORE UPDATE OR DELETE ON readings
+    FOR EACH ROW EXECUTE FUNCTION readings_signed_immutable();
@@ -0,0 +1,11 @@
+-- Alat yang belum pernah lulus kalibrasi mendapat satu interval kebijakan untuk kalibrasi pertama.
+-- Alat baru (baseline NULL) dihitung sejak didaftarkan; alat yang sudah terdaftar sebelum
+-- kalibrasi diwajibkan dihitung sejak migrasi ini, bukan sejak created_at.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'devices' AND column_name = 'calibration_baseline_at') THEN
+        ALTER TABLE devices ADD COLUMN calibration_baseline_at TIMESTAMP WITH TIME ZONE;
+        UPDATE devices SET calibration_baseline_at = NOW();
+    END IF;
+END $$;
@@ -1,4 +1,5 @@
 import 'dart:convert';
+import 'dart:math';
 
 class SignalPoint {
   final int freq;
@@ -58,6 +59,9 @@ class Location {
 }
 
 class Reading {
+  /// Generated once when the scan is taken and kept across sync retries so
+  /// the backend can drop duplicates.
+  final String clientReadingId;
   final String deviceSerial;
   final String patientId;
   final String doctorId;
@@ -68,6 +72,7 @@ class Reading {
   final AnalysisResult? analysis;
 
   Reading({
+    String? clientReadingId,
     required this.deviceSerial,
     required this.patientId,
     required this.doctorId,
@@ -75,7 +80,17 @@ class Reading {
     this.location,
     required this.rawSignalData,
     this.analysis,
-  });
+  }) : clientReadingId = clientReadingId ?? newClientReadingId();
+
+  /// Random RFC 4122 version 4 UUID.
+  static String newClientReadingId() {
+    final rnd = Random.secure();
+    final b = List<int>.generate(16, (_) => rnd.nextInt(256));
+    b[6] = (b[6] & 0x0f) | 0x40;
+    b[8] = (b[8] & 0x3f) | 0x80;
+    final h = b.map((x) => x.toRadixString(16).padLeft(2, '0')).join();
+    return '${h.substring(0, 8)}-${h.substring(8, 12)}-${h.substring(12, 16)}-${h.substring(16, 20)}-${h.substring(20)}';
+  }
 
   factory Reading.fromJson(Map<String, dynamic> j) {
     final readings = <dynamic>[];
@@ -102,6 +117,7 @@ class Reading {
     }
 
     return Reading(
+      clientReadingId: j['client_reading_id'] as String?,
       deviceSerial: j['device_serial'] as String? ?? j['deviceSerial'] as String? ?? '',
       patientId: j['patient_id'] as String? ?? j['patientId'] as String? ?? '',
       doctorId: j['doctor_id'] as String? ?? j['doctorId'] as String? ?? '',
@@ -114,6 +130,9 @@ class Reading {
 
   Map<String, dynamic> toJson() {
     return {
+      // nested layout (location / analysis) is schema v2 on the backend
+      'schema_version': 2,
+      'client_reading_id': clientReadingId,
       'device_serial': deviceSerial,
       'patient_id': patientId,
       'doctor_id': doctorId,
@@ -1,5 +1,8 @@
+import 'dart:convert';
 import 'dart:io';
+import 'dart:math';
 
+import 'package:crypto/crypto.dart';
 import 'package:dio/dio.dart';
 import '../models/reading_model.dart';
 
@@ -11,6 +14,44 @@ class SyncResponse {
   SyncResponse({required this.success, this.error, this.statusCode});
 }
 
+/// Credentials provisioned for a scanner (POST /devices/:serial/credentials).
+/// Sync requests are signed with them on the scanner's behalf.
+class DeviceCredentials {
+  final String serial;
+  final String secret;
+
+  DeviceCredentials({required this.serial, required this.secret});
+}
+
+/// Signs a device request the way the backend verifies it
+/// (auth.DeviceSignature): hex HMAC-SHA256 over
+/// `timestamp \n nonce \n METHOD \n path \n body`, timestamp in Unix seconds.
+Map<String, String> deviceSignatureHeaders(
+  DeviceCredentials device,
+  String method,
+  String path,
+  List<int> body, {
+  DateTime? now,
+  String? nonce,
+}) {
+  final ts = ((now ?? DateTime.now()).millisecondsSinceEpoch ~/ 1000).toString();
+  final n = nonce ?? _newNonce();
+  final mac = Hmac(sha256, utf8.encode(device.secret));
+  final signed = <int>[...utf8.encode('$ts\n$n\n${method.toUpperCase()}\n$path\n'), ...body];
+  return {
+    'X-Device-Serial': device.serial,
+    'X-Device-Timestamp': ts,
+    'X-Device-Nonce': n,
+    'X-Device-Signature': mac.convert(signed).toString(),
+  };
+}
+
+/// 128-bit random hex nonce; the backend rejects nonces it has seen.
+String _newNonce() {
+  final rnd = Random.secure();
+  return List<int>.generate(16, (_) => rnd.nextInt(256)).map((x) => x.toRadixString(16).padLeft(2, '0')).join();
+}
+
 class ApiService {
   final Dio _dio;
 
@@ -25,18 +66,31 @@ class ApiService {
     },
   ));
 
+  static const _syncPath = '/api/v1/sync/reading';
+
   /// Attempt to sync a reading to backend POST /api/v1/sync/reading
   /// Returns SyncResponse with detailed error info on failure.
-  Future<SyncResponse> syncReading(Reading reading, {String? token}) async {
+  ///
+  /// The backend only accepts syncs signed by a provisioned scanner, so
+  /// [device] must hold the credentials of the scanner that took the reading.
+  /// Every attempt is signed afresh; a retry never reuses a nonce.
+  Future<SyncResponse> syncReading(Reading reading, {required DeviceCredentials device, String? token}) async {
     try {
-      final payload = reading.toJson();
-      final headers = <String, dynamic>{};
+      // sign the exact bytes that go on the wire
+      final body = utf8.encode(reading.toRawJson());
+      final path = Uri.parse(_dio.options.baseUrl).path.replaceAll(RegExp(r'/+$'), '') + _syncPath;
+
+      // same key on every retry: the backend answers 200 with the original id
+      final headers = <String, dynamic>{
+        'Idempotency-Key': reading.clientReadingId,
+        ...deviceSignatureHeaders(device, 'POST', path, body),
+      };
       if (token != null && token.isNotEmpty) headers[HttpHeaders.authorizationHeader] = 'Bearer $token';
 
       final response = await _dio.post(
-        '/api/v1/sync/reading',
-        data: payload,
-        options: Options(headers: headers),
+        _syncPath,
+        data: Stream.fromIterable([body]),
+        options: Options(headers: {...headers, Headers.contentLengthHeader: body.length}),
       );
 
       if (response.statusCode != null && response.statusCode! >= 200 && response.statusCode! < 300) {